@@ -4,36 +4,39 @@ import (
 	"flag"
 	"fmt"
 	"log"
-	"math"
+	"os"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"gitlab.com/photo/e4f-go/src/e4f"
+	"gitlab.com/photo/e4f-go/src/geonames"
+	"gitlab.com/photo/e4f-go/src/tzgeo"
 	"gitlab.com/photo/e4f-go/src/xmp"
 
 	"C"
 )
 
-// Convert a float to a GpsCoord from XMP. dir is either 'N' or 'E'.
-// Sign will change it.
-func floatToGpsCoord(f float64, dir byte) string {
-
-	negative := math.Signbit(f)
-	if negative {
-		switch dir {
-		case 'N':
-			dir = 'S'
-		case 'E':
-			dir = 'W'
-		}
-	}
-
-	f = math.Abs(f)
-	degs := math.Floor(f)
-
-	minutes := (f - degs) * 60
-	return fmt.Sprintf("%d,%f%c", int(degs), minutes, dir)
+// Options for the exporters.
+var options = struct {
+	// Number of decimals for the minutes of XMP GPS coordinates.
+	GpsPrecision int
+	// The time zone of the e4f timestamps.
+	TimeZone *time.Location
+	// To fill the location fields, if set.
+	Geocoder *geocoder
+	// The time zone of each exposure, by id, if resolved.
+	Zones map[int]*time.Location
+	// The time zone of each roll, by id, if set.
+	RollZones map[int]*time.Location
+	// How the frames are written to XMP.
+	Mapping *mapping
+	// The rights by artist, if set.
+	Rights rightsConfig
+}{
+	GpsPrecision: e4f.DefaultGpsPrecision,
+	TimeZone:     time.Local,
 }
 
 // Print in text form the exposure.
@@ -46,257 +49,374 @@ func exposureToText(db *e4f.E4fDb, roll *e4f.ExposedRoll, exp *e4f.Exposure,
 		aperture = fmt.Sprintf("f/%.1f", f)
 	}
 
-	shootInfo := fmt.Sprintf("%s %s %dmm", exp.ShutterSpeed, aperture, exp.FocalLength)
-	gps, found := db.GpsMap[exp.GpsLocId]
-	if gps != nil && found {
+	frame := db.Frame(roll, exp, index)
+	shootInfo := fmt.Sprintf("%s %s %gmm", exp.ShutterSpeed, aperture, exp.FocalLength)
+	if gps := frame.Gps; gps != nil {
 		shootInfo += fmt.Sprintf("\n\tLong %f Lat %f", gps.Long, gps.Lat)
+		if frame.GpsEstimated {
+			shootInfo += " (estimated)"
+		}
+	}
+
+	timeTaken := exp.TimeTaken
+	if t, ok := frame.Time(); ok && frame.TimeEstimated {
+		timeTaken = e4f.FormatTime(t) + " (estimated)"
 	}
 
 	return fmt.Sprintf("Frame %d, %s %s\n\t%s",
-		index+1, exp.TimeTaken, shootInfo,
+		index+1, timeTaken, shootInfo,
 		exp.Desc)
 }
 
+// Unknown values already reported.
+var reportedErrors = make(map[string]bool)
+
+// Report err, only once for the same message.
+func reportOnce(err error) {
+	if err == nil || reportedErrors[err.Error()] {
+		return
+	}
+	reportedErrors[err.Error()] = true
+	log.Printf("Warning: %s", err)
+}
+
+// Return the frame MeteringMode and LightSource, reporting the
+// unknown values.
+func frameEnums(frame *e4f.Frame) (meteringMode int, lightSource int) {
+	meteringMode, err := frame.MeteringMode()
+	reportOnce(err)
+	lightSource, err = frame.LightSource()
+	reportOnce(err)
+	return
+}
+
 // Generate XMP for a single exposure
 func exposureToXmp(db *e4f.E4fDb, roll *e4f.ExposedRoll, exp *e4f.Exposure,
 	index int) xmp.Xmp {
 
 	x := xmp.NewEmpty()
+	fillExposureXmp(x, db, roll, exp, index)
+	return x
+}
+
+// Set the XMP properties for a single exposure into x.
+func fillExposureXmp(x xmp.Xmp, db *e4f.E4fDb, roll *e4f.ExposedRoll,
+	exp *e4f.Exposure, index int) {
+
+	options.Mapping.apply(x, db.Frame(roll, exp, index))
+}
+
+// ByLabel implements sort.Interface for []Person based on
+// the Age field.
+type ByLabel []*e4f.ExposedRoll
+
+func (a ByLabel) Len() int           { return len(a) }
+func (a ByLabel) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a ByLabel) Less(i, j int) bool { return a[i].Desc < a[j].Desc }
+
+func main() {
+
+	formatPtr := flag.String("format", "xmp",
+		"Output format. Value: xmp, text, exiftool, exiftool-json, gpx,\n"+
+			"geojson or kml. json for -lint and -cameras")
+	dumpPtr := flag.Bool("dump", false, "Dump the content")
+	listPtr := flag.Bool("list", false, "List rolls")
+	tracksPtr := flag.Bool("tracks", false,
+		"With -format gpx, also output a track per roll")
+	rollNumPtr := flag.Int("roll", 0, "Roll number. 0 = all")
+	scansPtr := flag.String("scans", "",
+		"Directory of the scans of the roll. Needs a single roll")
+	writeExifPtr := flag.Bool("write-exif", false,
+		"Write EXIF into the JPEG and TIFF scans. Needs -scans")
+	writeXmpPtr := flag.Bool("write-xmp", false,
+		"Write the XMP sidecars of the scans. Needs -scans")
+	scannerPtr := flag.String("scanner", string(scannerMove),
+		"What to do with the scanner found in the scans. Value: move, keep or overwrite")
+	mappingPtr := flag.String("mapping", "",
+		"JSON file mapping the frames to XMP properties, instead of the built-in one. Doesn't change the EXIF")
+	printMappingPtr := flag.Bool("print-mapping", false,
+		"Print the built-in mapping to XMP")
+	camerasPtr := flag.Bool("cameras", false,
+		"Print when the rolls were in each camera, the overlaps and the gaps")
+	lintPtr := flag.Bool("lint", false,
+		"Check the rolls for impossible or suspicious data. With -format json\n"+
+			"output JSON. Exit status is 1 if there are errors")
+	importPtr := flag.Bool("import", false,
+		"The argument is a directory of XMP sidecars or AnalogExif tagged scans\n"+
+			"to rebuild the rolls from, instead of an e4f export")
+	verifyPtr := flag.Bool("verify", false,
+		"Command: verify the XMP of the scans in -scans against the roll, as\n"+
+			"written with -scanner, instead of writing it. Needs a single roll.\n"+
+			"Exit status is 1 if there is drift")
+
+	gpsPrecisionPtr := flag.Int("gps-precision", e4f.DefaultGpsPrecision,
+		"Number of decimals for the minutes of the XMP GPS coordinates")
+	privacyPtr := flag.String("privacy", "",
+		"JSON file with the private zones and the GPS fuzzing grid")
+	stripPtr := flag.String("strip", "",
+		"Strip before exporting. Comma separated list of gps, serials, descriptions")
+	tzPtr := flag.String("tz", "Local",
+		"Time zone of the e4f timestamps, like America/Montreal")
+	estimatePtr := flag.Int("estimate", 0,
+		"Estimate the missing times and locations from the frames at most this many numbers apart. 0 to not estimate")
+	timeShiftsPtr := flag.String("time-shifts", "",
+		"JSON file with the corrections of the phone clock")
+	tzRollsPtr := flag.String("tz-rolls", "",
+		"Time zone of the rolls, before -tz. Comma separated list of roll=zone,\n"+
+			"like 3=Europe/Paris")
+	tzBoundariesPtr := flag.String("tz-boundaries", "",
+		"GeoJSON of the time zone boundaries, to find the time zone of each frame")
+	tzFallbackPtr := flag.String("tz-fallback", string(zoneNearest),
+		"Time zone of the frames without location. Value: nearest or default")
+	artistsPtr := flag.String("artists", "",
+		"JSON file with the rules attributing the rolls and frames to artists")
+	rightsPtr := flag.String("rights", "",
+		"JSON file with the copyright, license and contact of each artist")
+	geonamesPtr := flag.String("geonames", "",
+		"GeoNames dump, like cities1000.txt, to fill the city, state and country")
+	geotagPtr := flag.String("geotag", "",
+		"Locate the frames without location from GPX tracks. Comma separated list of files")
+	geotagOffsetPtr := flag.Duration("geotag-offset", 0,
+		"Added to the frame times to match the GPX tracks, like -1m30s")
+	geotagMaxGapPtr := flag.Duration("geotag-max-gap", 5*time.Minute,
+		"Maximum time between a frame and the track points")
 
-	xmp.SetProperty(x, xmp.NS_EXIF_AUX, "ImageNumber",
-		fmt.Sprintf("%d", index+1), 0)
-	xmp.SetProperty(x, xmp.NS_ANALOG, "ExposureNumber",
-		fmt.Sprintf("%d", index+1), 0)
+	flag.Parse()
 
-	if exp.Desc != "" {
-		xmp.SetProperty(x, xmp.NS_DC, "description", exp.Desc, 0)
+	options.GpsPrecision = *gpsPrecisionPtr
+	loc, err := time.LoadLocation(*tzPtr)
+	if err != nil {
+		log.Fatal(err)
 	}
-	// Artist
-	if len(db.Artists) > 0 {
-		artist := db.Artists[0]
-		if artist.Name != "" {
-			xmp.AppendArrayItem(x, xmp.NS_DC, "creator",
-				xmp.PROP_VALUE_IS_ARRAY, artist.Name, 0)
+	options.TimeZone = loc
+	if *tzRollsPtr != "" {
+		options.RollZones, err = parseRollZones(*tzRollsPtr)
+		if err != nil {
+			log.Fatal(err)
 		}
 	}
-	// DateTime
-	if exp.TimeTaken != "" {
-		xmp.SetProperty(x, xmp.NS_EXIF, "DateTimeOriginal", exp.TimeTaken, 0)
-	}
-	// ISO
-	if roll.Iso != 0 {
-		xmp.AppendArrayItem(x, xmp.NS_EXIF, "ISOSpeedRatings",
-			xmp.PROP_VALUE_IS_ARRAY, fmt.Sprintf("%d", roll.Iso), 0)
-	}
-	// Shutter speed
-	if exp.ShutterSpeed != "" {
-		xmp.SetProperty(x, xmp.NS_EXIF, "ShutterSpeedValue",
-			exp.ShutterSpeed, 0)
+
+	if *printMappingPtr {
+		os.Stdout.Write(defaultMappingJson)
+		return
 	}
-	// Aperture
-	f, err := strconv.ParseFloat(exp.Aperture, 64)
-	if err == nil {
-		xmp.SetProperty(x, xmp.NS_EXIF, "FNumber",
-			fmt.Sprintf("%d/10", int(f*10)), 0)
+	if *mappingPtr != "" {
+		options.Mapping, err = loadMapping(*mappingPtr)
+		if err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		options.Mapping = defaultMapping()
 	}
 
-	// FocalLength
-	if exp.FocalLength != 0 {
-		xmp.SetProperty(x, xmp.NS_EXIF, "FocalLength",
-			fmt.Sprintf("%d", exp.FocalLength), 0)
+	args := flag.Args()
+	if len(args) < 1 {
+		flag.PrintDefaults()
+		log.Fatal("Not enough args.")
 	}
-	// Camera
-	camera, found := db.CameraMap[roll.CameraId]
-	if camera != nil && found {
-		mk := db.MakeMap[camera.MakeId]
-		if mk.Name != "" {
-			xmp.SetProperty(x, xmp.NS_TIFF, "Make",
-				mk.Name, 0)
-		}
-		if camera.Title != "" {
-			xmp.SetProperty(x, xmp.NS_TIFF, "Model",
-				camera.Title, 0)
-		}
-		if camera.SerialNumber != "" {
-			xmp.SetProperty(x, xmp.NS_EXIF_AUX, "SerialNumber",
-				camera.SerialNumber, 0)
+
+	path := args[0]
+	var e4fDb *e4f.E4fDb
+	if *importPtr {
+		e4fDb, err = importScans(path)
+		if err != nil {
+			log.Fatal(err)
 		}
+	} else {
+		e4fDb = e4f.Parse(path)
 	}
 
-	// Lens
-	lens, found := db.LensMap[exp.LensId]
-	if lens != nil && found {
-		canLensInfo := true
-		// in Exif MaxApertureValue is the widest aperture,
-		// ie the lowest number. Unlike in e4f
-		apMin, err := strconv.ParseFloat(lens.ApertureMin, 64)
-		if err == nil {
-			xmp.SetProperty(x, xmp.NS_EXIF, "MaxApertureValue",
-				fmt.Sprintf("%d/10", int(apMin*10)), 0)
-		} else {
-			canLensInfo = false
-		}
-		maker := db.MakeMap[lens.MakeId]
-		// We need to normalize the name
-		var lensDesc string
-		if strings.HasPrefix(lens.Title, maker.Name) {
-			lensDesc = lens.Title
-		} else {
-			lensDesc = fmt.Sprintf("%s %s", maker.Name, lens.Title)
+	if *timeShiftsPtr != "" {
+		shifts, err := e4f.LoadTimeShifts(*timeShiftsPtr)
+		if err != nil {
+			log.Fatal(err)
 		}
-		xmp.SetProperty(x, xmp.NS_EXIF_AUX, "Lens",
-			lensDesc, 0)
-
-		canLensInfo = canLensInfo && lens.FocalLengthMin != 0 &&
-			lens.FocalLengthMax != 0
-		if canLensInfo {
-			apMax, err := strconv.ParseFloat(lens.ApertureMax, 64)
-			if err == nil {
-				canLensInfo = false
+		for i := range shifts.Shifts {
+			shift := &shifts.Shifts[i]
+			err = e4fDb.ApplyTimeShift(shift,
+				rollZone(shift.Roll, options.TimeZone))
+			if err != nil {
+				log.Fatal(err)
 			}
-			lensInfo := fmt.Sprintf("%d/1 %d/1 %d/10 %d/10",
-				lens.FocalLengthMin, lens.FocalLengthMax,
-				int(apMin*10), int(apMax*10))
-			xmp.SetProperty(x, xmp.NS_EXIF_AUX, "LensInfo",
-				lensInfo, 0)
 		}
+	}
 
-		if lens.SerialNumber != "" {
-			xmp.SetProperty(x, xmp.NS_EXIF_AUX, "LensSerialNumber",
-				lens.SerialNumber, 0)
-			xmp.SetProperty(x, xmp.NS_ANALOG, "LensSerialNumber",
-				lens.SerialNumber, 0)
+	if *geotagPtr != "" {
+		track, err := readTracks(*geotagPtr)
+		if err != nil {
+			log.Fatal(err)
 		}
+		results := e4fDb.Geotag(track, e4f.GeotagOptions{
+			Location:      options.TimeZone,
+			RollLocations: options.RollZones,
+			Offset:        *geotagOffsetPtr,
+			MaxGap:        *geotagMaxGapPtr,
+		})
+		printGeotagReport(os.Stderr, results)
 	}
 
-	// Film
-	film, found := db.FilmMap[roll.FilmId]
-	if film != nil && found {
-		if roll.Desc != "" {
-			xmp.SetProperty(x, xmp.NS_ANALOG, "RollId", roll.Desc,
-				0)
-		}
-		mk := db.MakeMap[film.MakeId]
-		var filmMake string
-		if mk != nil && mk.Name != "" {
-			filmMake = mk.Name
-			xmp.SetProperty(x, xmp.NS_ANALOG, "FilmMaker", filmMake,
-				0)
+	if *estimatePtr > 0 {
+		e4fDb.EstimateMissing(*estimatePtr)
+	}
+
+	if *artistsPtr != "" {
+		attribution, err := e4f.LoadAttribution(*artistsPtr)
+		if err != nil {
+			log.Fatal(err)
 		}
-		if film.Title != "" {
-			if filmMake != "" {
-				xmp.SetProperty(x, xmp.NS_ANALOG, "Film",
-					fmt.Sprintf("%s %s", mk.Name,
-						film.Title), 0)
-			} else {
-				xmp.SetProperty(x, xmp.NS_ANALOG, "Film",
-					film.Title, 0)
-			}
+		if err = e4fDb.ApplyAttribution(attribution); err != nil {
+			log.Fatal(err)
 		}
+	}
 
-		if filmType := roll.FilmType; filmType != "" {
-			switch filmType {
-			case "F120":
-				filmType = "120"
-			case "F220":
-				filmType = "220"
-			case "F135":
-				filmType = "135"
-			}
-			xmp.SetProperty(x, xmp.NS_ANALOG, "FilmType",
-				filmType, 0)
+	if *tzBoundariesPtr != "" {
+		fallback, err := parseZoneFallback(*tzFallbackPtr)
+		if err != nil {
+			log.Fatal(err)
 		}
-		if film.Process != "" {
-			xmp.SetProperty(x, xmp.NS_ANALOG, "FilmProcess",
-				film.Process, 0)
+		boundaries, err := tzgeo.Load(*tzBoundariesPtr)
+		if err != nil {
+			log.Fatal(err)
 		}
+		options.Zones = resolveZones(e4fDb, boundaries, fallback,
+			options.TimeZone)
 	}
 
-	// Flash
-	flash := "false"
-	if exp.FlashOn {
-		flash = "true"
-	}
-	xmp.SetProperty(x, xmp.NS_EXIF, "Flash/exif:Fired", flash, 0)
-	// Metering
-	var meteringMode = 0
-	switch exp.MeteringMode {
-	case "Unknown":
-		meteringMode = 0
-	case "Average":
-		meteringMode = 1
-		// TODO finish
-	}
-	xmp.SetProperty(x, xmp.NS_EXIF, "MeteringMode",
-		fmt.Sprintf("%d", meteringMode), 0)
-
-	// Light source
-	var lightSource = 0
-	switch exp.LightSource {
-	case "Daylight":
-		lightSource = 1
-	}
-	xmp.SetProperty(x, xmp.NS_EXIF, "LightSource",
-		fmt.Sprintf("%d", lightSource), 0)
-	// Gps
-	gps, found := db.GpsMap[exp.GpsLocId]
-	if gps != nil && found {
-		// create a fraction. Assume 1/10th of meter precision
-		alt := gps.Alt * 10
-		xmp.SetProperty(x, xmp.NS_EXIF, "GPSAltitude",
-			fmt.Sprintf("%d/10", int(alt)), 0)
-
-		coord := floatToGpsCoord(gps.Lat, 'N')
-		xmp.SetProperty(x, xmp.NS_EXIF, "GPSLatitude", coord, 0)
-
-		coord = floatToGpsCoord(gps.Long, 'E')
-		xmp.SetProperty(x, xmp.NS_EXIF, "GPSLongitude", coord, 0)
+	if *rightsPtr != "" {
+		options.Rights, err = loadRights(*rightsPtr)
+		if err != nil {
+			log.Fatal(err)
+		}
 	}
 
-	return x
-}
-
-// ByLabel implements sort.Interface for []Person based on
-// the Age field.
-type ByLabel []*e4f.ExposedRoll
-
-func (a ByLabel) Len() int           { return len(a) }
-func (a ByLabel) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a ByLabel) Less(i, j int) bool { return a[i].Desc < a[j].Desc }
-
-func main() {
-
-	formatPtr := flag.String("format", "xmp",
-		"Output format. Value: xmp or text")
-	dumpPtr := flag.Bool("dump", false, "Dump the content")
-	listPtr := flag.Bool("list", false, "List rolls")
-	rollNumPtr := flag.Int("roll", 0, "Roll number. 0 = all")
-
-	flag.Parse()
-
-	args := flag.Args()
-	if len(args) < 1 {
-		flag.PrintDefaults()
-		log.Fatal("Not enough args.")
+	if *geonamesPtr != "" {
+		index, err := geonames.Load(*geonamesPtr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		options.Geocoder = newGeocoder(index)
 	}
 
-	path := args[0]
-	e4fDb := e4f.Parse(path)
+	if *privacyPtr != "" || *stripPtr != "" {
+		privacy := &e4f.Privacy{}
+		if *privacyPtr != "" {
+			privacy, err = e4f.LoadPrivacy(*privacyPtr)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		if err = privacy.SetStrip(*stripPtr); err != nil {
+			log.Fatal(err)
+		}
+		e4fDb.ApplyPrivacy(privacy)
+	}
 
 	var rolls []*e4f.ExposedRoll
-	if *rollNumPtr > 0 {
+	if *rollNumPtr > len(e4fDb.ExposedRolls) {
+		log.Fatalf("No roll %d, there are %d.", *rollNumPtr,
+			len(e4fDb.ExposedRolls))
+	} else if *rollNumPtr > 0 {
 		rolls = e4fDb.ExposedRolls[*rollNumPtr-1 : *rollNumPtr]
 	} else {
 		rolls = e4fDb.ExposedRolls
 	}
 	sort.Sort(ByLabel(rolls))
+
+	if *camerasPtr {
+		printCameraTimelines(os.Stdout, e4fDb)
+		return
+	}
+
+	if *lintPtr {
+		failed, err := lintRolls(os.Stdout, e4fDb, rolls,
+			*formatPtr == "json")
+		if err != nil {
+			log.Fatal(err)
+		}
+		if failed {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *verifyPtr {
+		if len(rolls) != 1 {
+			log.Fatalf("-verify needs a single roll, %d selected. Use -roll.",
+				len(rolls))
+		}
+		if *scansPtr == "" {
+			log.Fatal("-verify needs -scans.")
+		}
+		policy, err := parseScannerPolicy(*scannerPtr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !verifyRoll(e4fDb, rolls[0], *scansPtr, policy) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Formats outputting one document for all the rolls.
+	dump := *dumpPtr
+	if dump {
+		switch *formatPtr {
+		case "gpx":
+			err = writeGpx(os.Stdout, e4fDb, rolls, *tracksPtr)
+			dump = false
+		case "geojson":
+			err = writeGeoJson(os.Stdout, e4fDb, rolls)
+			dump = false
+		case "kml":
+			err = writeKml(os.Stdout, e4fDb, rolls)
+			dump = false
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	for idx, roll := range rolls {
 		id := roll.Id
 		if *listPtr {
 			fmt.Printf("Roll %d:\n", idx+1)
 			e4fDb.Print(roll)
 		}
-		if *dumpPtr {
+		if *writeExifPtr || *writeXmpPtr {
+			if len(rolls) != 1 || *scansPtr == "" {
+				log.Fatal("Writing scans needs a single roll and -scans.")
+			}
+			policy, err := parseScannerPolicy(*scannerPtr)
+			if err != nil {
+				log.Fatal(err)
+			}
+			scans, err := matchScans(*scansPtr,
+				e4fDb.ExposuresForRoll(id))
+			if err != nil {
+				log.Fatal(err)
+			}
+			writeScans(e4fDb, roll, scans, *writeXmpPtr,
+				*writeExifPtr, policy)
+		}
+		if dump && strings.HasPrefix(*formatPtr, "exiftool") {
+			if len(rolls) != 1 || *scansPtr == "" {
+				log.Fatal("exiftool needs a single roll and -scans.")
+			}
+			frames := e4fDb.FramesForRoll(roll)
+			scans, err := matchScans(*scansPtr,
+				e4fDb.ExposuresForRoll(id))
+			if err != nil {
+				log.Fatal(err)
+			}
+			if *formatPtr == "exiftool-json" {
+				err = writeExiftoolJson(os.Stdout, frames, scans)
+				if err != nil {
+					log.Fatal(err)
+				}
+			} else {
+				writeExiftoolArgs(os.Stdout, frames, scans)
+			}
+		} else if dump {
 			exps := e4fDb.ExposuresForRoll(id)
 			for i, exp := range exps {
 				if *formatPtr == "xmp" {
@@ -316,6 +436,9 @@ func main() {
 					fmt.Println(t)
 				}
 			}
+			if *formatPtr == "text" {
+				printRollPlaces(os.Stdout, e4fDb.FramesForRoll(roll))
+			}
 		}
 	}
 }
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"gitlab.com/photo/e4f-go/src/e4f"
+)
+
+// An exiftool tag assignment. A tag ending with '#' takes
+// the numeric value.
+type exiftoolTag struct {
+	tag   string
+	value string
+}
+
+// Generate the exiftool tags for a frame. This is the built-in
+// mapping, using the EXIF tags when they exist: -mapping doesn't
+// change it.
+func frameToExiftool(frame *e4f.Frame) (tags []exiftoolTag) {
+	set := func(tag string, value string) {
+		tags = append(tags, exiftoolTag{tag, value})
+	}
+	exp := frame.Exposure
+	roll := frame.Roll
+
+	set("XMP-aux:ImageNumber", strconv.Itoa(frame.Number()))
+	set("XMP-AnalogExif:ExposureNumber", strconv.Itoa(frame.Number()))
+
+	if exp.Desc != "" {
+		set("EXIF:ImageDescription", exp.Desc)
+		set("XMP-dc:Description", exp.Desc)
+	}
+	if names := frame.ArtistNames(); len(names) > 0 {
+		set("EXIF:Artist", strings.Join(names, "; "))
+		for _, name := range names {
+			set("XMP-dc:Creator", name)
+		}
+	}
+	frameRightsToExiftool(frame, set)
+	if t, ok := frame.Time(); ok {
+		set("EXIF:DateTimeOriginal", t.Format(e4f.ExifTimeLayout))
+	}
+	if loc, resolved := frameZone(frame); resolved {
+		if t, ok := frame.TimeIn(loc); ok {
+			set("EXIF:OffsetTimeOriginal", t.Format("-07:00"))
+		}
+	}
+	if roll.Iso != 0 {
+		set("EXIF:ISO", strconv.Itoa(roll.Iso))
+	}
+	if _, ok := frame.PushPull(); ok {
+		sensitivityType := frame.SensitivityType()
+		set("EXIF:SensitivityType#", strconv.Itoa(sensitivityType))
+		if sensitivityType == e4f.SensitivityRecommendedExposureIndex {
+			set("EXIF:RecommendedExposureIndex", strconv.Itoa(roll.Iso))
+		}
+	}
+	if exp.ShutterSpeed != "" {
+		set("EXIF:ExposureTime", exp.ShutterSpeed)
+	}
+	if f, ok := frame.Aperture(); ok {
+		set("EXIF:FNumber", strconv.FormatFloat(f, 'f', -1, 64))
+	}
+	if exp.FocalLength != 0 {
+		set("EXIF:FocalLength",
+			strconv.FormatFloat(exp.FocalLength, 'f', -1, 64))
+	}
+
+	if camera := frame.Camera; camera != nil {
+		if mk := frame.CameraMakeName(); mk != "" {
+			set("EXIF:Make", mk)
+		}
+		if camera.Title != "" {
+			set("EXIF:Model", camera.Title)
+		}
+		if camera.SerialNumber != "" {
+			set("EXIF:SerialNumber", camera.SerialNumber)
+		}
+	}
+
+	if lens := frame.Lens; lens != nil {
+		apMin, apMax, canLensInfo := frame.LensApertures()
+		if apMin != 0 {
+			set("EXIF:MaxApertureValue",
+				strconv.FormatFloat(apMin, 'f', -1, 64))
+		}
+		set("EXIF:LensModel", frame.LensDescription())
+		if canLensInfo && lens.FocalLengthMin != 0 &&
+			lens.FocalLengthMax != 0 {
+			set("EXIF:LensInfo", fmt.Sprintf("%g %g %g %g",
+				lens.FocalLengthMin, lens.FocalLengthMax,
+				apMin, apMax))
+		}
+		if lens.SerialNumber != "" {
+			set("EXIF:LensSerialNumber", lens.SerialNumber)
+			set("XMP-AnalogExif:LensSerialNumber",
+				lens.SerialNumber)
+		}
+	}
+
+	if film := frame.Film; film != nil {
+		if roll.Desc != "" {
+			set("XMP-AnalogExif:RollId", roll.Desc)
+		}
+		if filmMake := frame.FilmMakeName(); filmMake != "" {
+			set("XMP-AnalogExif:FilmMaker", filmMake)
+		}
+		if label := frame.FilmLabel(); label != "" {
+			set("XMP-AnalogExif:Film", label)
+		}
+		if filmType := frame.FilmType(); filmType != "" {
+			set("XMP-AnalogExif:FilmType", filmType)
+		}
+		if film.Process != "" {
+			set("XMP-AnalogExif:FilmProcess", film.Process)
+		}
+	}
+
+	meteringMode, lightSource := frameEnums(frame)
+	set("EXIF:Flash#", strconv.Itoa(frame.Flash().Value()))
+	set("EXIF:MeteringMode#", strconv.Itoa(meteringMode))
+	set("EXIF:LightSource#", strconv.Itoa(lightSource))
+
+	if gps := frame.Gps; gps != nil {
+		v := e4f.GpsVersionID
+		set("EXIF:GPSVersionID", fmt.Sprintf("%d.%d.%d.%d", v[0], v[1],
+			v[2], v[3]))
+		set("EXIF:GPSMapDatum", e4f.GpsMapDatum)
+		set("EXIF:GPSLatitude", strconv.FormatFloat(math.Abs(gps.Lat),
+			'f', -1, 64))
+		set("EXIF:GPSLatitudeRef", string(e4f.GpsRef(gps.Lat, 'N')))
+		set("EXIF:GPSLongitude", strconv.FormatFloat(math.Abs(gps.Long),
+			'f', -1, 64))
+		set("EXIF:GPSLongitudeRef", string(e4f.GpsRef(gps.Long, 'E')))
+		alt, altRef := e4f.GpsAltitude(gps.Alt)
+		set("EXIF:GPSAltitude", fmt.Sprintf("%.1f", float64(alt)/10))
+		set("EXIF:GPSAltitudeRef#", strconv.Itoa(altRef))
+		if t, ok := frameUTCTime(frame); ok {
+			set("EXIF:GPSDateStamp", t.Format("2006:01:02"))
+			set("EXIF:GPSTimeStamp", t.Format("15:04:05"))
+		}
+	}
+	if p := framePlace(frame); p != nil {
+		setIf := func(tag string, value string) {
+			if value != "" {
+				set(tag, value)
+			}
+		}
+		setIf("XMP-photoshop:City", p.City)
+		setIf("XMP-photoshop:State", p.State)
+		setIf("XMP-photoshop:Country", p.Country)
+		setIf("XMP-iptcCore:CountryCode", p.CountryCode)
+		setIf("XMP-iptcCore:Location", p.Location)
+	}
+	return
+}
+
+// Generate the exiftool tags for the rights of the frame artist.
+func frameRightsToExiftool(frame *e4f.Frame, set func(string, string)) {
+	r := frameRights(frame)
+	if r == nil {
+		return
+	}
+	setIf := func(tag string, value string) {
+		if value == "" {
+			return
+		}
+		if value, ok := expandYear(value, frame); ok {
+			set(tag, value)
+		}
+	}
+	if copyright, ok := expandYear(r.Copyright, frame); ok &&
+		copyright != "" {
+		set("EXIF:Copyright", copyright)
+		set("XMP-dc:Rights", copyright)
+	}
+	if r.Marked != nil {
+		set("XMP-xmpRights:Marked", strconv.FormatBool(*r.Marked))
+	}
+	setIf("XMP-xmpRights:UsageTerms", r.UsageTerms)
+	setIf("XMP-xmpRights:WebStatement", r.WebStatement)
+	setIf("XMP-cc:License", r.License)
+	if c := r.Contact; c != nil {
+		setIf("XMP-iptcCore:CreatorAddress", c.Address)
+		setIf("XMP-iptcCore:CreatorCity", c.City)
+		setIf("XMP-iptcCore:CreatorRegion", c.Region)
+		setIf("XMP-iptcCore:CreatorPostalCode", c.PostalCode)
+		setIf("XMP-iptcCore:CreatorCountry", c.Country)
+		setIf("XMP-iptcCore:CreatorWorkTelephone", c.Phone)
+		setIf("XMP-iptcCore:CreatorWorkEmail", c.Email)
+		setIf("XMP-iptcCore:CreatorWorkURL", c.Url)
+	}
+}
+
+// exiftool reads each line of an argument file as an argument.
+var lineBreaks = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ")
+
+// Write an exiftool argument file, for use with "exiftool -@ FILE".
+// Each frame is a command applied to its scan, separated by -execute.
+// The line breaks in the values are made spaces, and the scans with
+// one in their path are skipped.
+func writeExiftoolArgs(w io.Writer, frames []*e4f.Frame, scans []string) {
+	for i, frame := range frames {
+		if scans[i] == "" {
+			continue
+		}
+		if strings.ContainsAny(scans[i], "\r\n") {
+			reportOnce(fmt.Errorf("frame %d: line break in %q, skipped",
+				frame.Number(), scans[i]))
+			continue
+		}
+		fmt.Fprintf(w, "# Frame %d\n", frame.Number())
+		for _, tag := range frameToExiftool(frame) {
+			value := tag.value
+			if strings.ContainsAny(value, "\r\n") {
+				reportOnce(fmt.Errorf("frame %d: line breaks of %s made spaces",
+					frame.Number(), tag.tag))
+				value = lineBreaks.Replace(value)
+			}
+			fmt.Fprintf(w, "-%s=%s\n", tag.tag, value)
+		}
+		fmt.Fprintf(w, "%s\n-execute\n", scans[i])
+	}
+}
+
+// Write the frames in the exiftool JSON format, for use with
+// "exiftool -json=FILE".
+func writeExiftoolJson(w io.Writer, frames []*e4f.Frame,
+	scans []string) error {
+	var objects []map[string]interface{}
+	for i, frame := range frames {
+		if scans[i] == "" {
+			continue
+		}
+		object := map[string]interface{}{"SourceFile": scans[i]}
+		for _, tag := range frameToExiftool(frame) {
+			// A tag set again is a list, like XMP-dc:Creator.
+			switch value := object[tag.tag].(type) {
+			case nil:
+				object[tag.tag] = tag.value
+			case string:
+				object[tag.tag] = []string{value, tag.value}
+			case []string:
+				object[tag.tag] = append(value, tag.value)
+			}
+		}
+		objects = append(objects, object)
+	}
+
+	buffer, err := json.MarshalIndent(objects, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", buffer)
+	return err
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"gitlab.com/photo/e4f-go/src/e4f"
+)
+
+// A value on several lines stays one argument.
+func TestExiftoolArgsLineBreaks(t *testing.T) {
+	db := e4f.Parse("samples/export-Roll-20130630_203650.xml")
+	roll := db.ExposedRolls[0]
+	frames := db.FramesForRoll(roll)[:2]
+	frames[0].Exposure.Desc = "Harbour\n-all=\r\nat dusk"
+	scans := []string{"scans/01.jpg", "scans/02\n-all=.jpg"}
+
+	var buffer bytes.Buffer
+	writeExiftoolArgs(&buffer, frames, scans)
+	for _, line := range strings.Split(strings.TrimSuffix(buffer.String(),
+		"\n"), "\n") {
+		if line == "-all=" || line == "at dusk" || strings.HasPrefix(line, "02") {
+			t.Errorf("Line %q", line)
+		}
+	}
+	if !strings.Contains(buffer.String(), "Harbour -all= at dusk\n") {
+		t.Errorf("No description in:\n%s", buffer.String())
+	}
+	if strings.Contains(buffer.String(), "# Frame 2\n") {
+		t.Errorf("Frame 2 written:\n%s", buffer.String())
+	}
+}
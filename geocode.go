@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"gitlab.com/photo/e4f-go/src/e4f"
+	"gitlab.com/photo/e4f-go/src/geonames"
+)
+
+// The location of a frame, for the IPTC fields.
+type place struct {
+	City, State, Country, CountryCode string
+	// A named feature near by, not the city.
+	Location string
+}
+
+// Label "City, State, Country", skipping the empty parts.
+func (p *place) String() string {
+	var parts []string
+	for _, part := range []string{p.City, p.State, p.Country} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Maximum distance to a feature for the Location.
+const locationRadius = 1000
+
+// Cells searched around a location for the city.
+const cityRings = 2
+
+// Reverse geocode the locations, caching the places per location id.
+type geocoder struct {
+	index  *geonames.Index
+	places map[int]*place
+}
+
+func newGeocoder(index *geonames.Index) *geocoder {
+	return &geocoder{index, make(map[int]*place)}
+}
+
+func isPopulated(p *geonames.Place) bool {
+	return p.Class == 'P'
+}
+
+// Return the place of the location, or nil if none is close.
+func (g *geocoder) lookup(gps *e4f.GpsLocation) *place {
+	if p, found := g.places[gps.Id]; found {
+		return p
+	}
+
+	var p *place
+	city, _ := g.index.Nearest(gps.Lat, gps.Long, isPopulated, cityRings)
+	if city != nil {
+		p = &place{
+			City:        city.Name,
+			State:       city.State,
+			Country:     city.Country,
+			CountryCode: city.CountryCode,
+		}
+		feature, dist := g.index.Nearest(gps.Lat, gps.Long,
+			func(f *geonames.Place) bool { return !isPopulated(f) }, 1)
+		if feature != nil && dist <= locationRadius {
+			p.Location = feature.Name
+		}
+	}
+	g.places[gps.Id] = p
+	return p
+}
+
+// Return the place of the frame, or nil.
+func framePlace(frame *e4f.Frame) *place {
+	if options.Geocoder == nil || frame.Gps == nil {
+		return nil
+	}
+	return options.Geocoder.lookup(frame.Gps)
+}
+
+// Print the places of the roll with their number of frames.
+func printRollPlaces(w io.Writer, frames []*e4f.Frame) {
+	if options.Geocoder == nil {
+		return
+	}
+	counts := make(map[string]int)
+	for _, frame := range frames {
+		if p := framePlace(frame); p != nil {
+			counts[p.String()]++
+		}
+	}
+	var labels []string
+	for label := range counts {
+		labels = append(labels, label)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		if counts[labels[i]] != counts[labels[j]] {
+			return counts[labels[i]] > counts[labels[j]]
+		}
+		return labels[i] < labels[j]
+	})
+	for _, label := range labels {
+		fmt.Fprintf(w, "Place %s: %d frames\n", label, counts[label])
+	}
+}
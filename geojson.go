@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"gitlab.com/photo/e4f-go/src/e4f"
+)
+
+// Colors of the rolls on the map, as "#rrggbb", cycled through.
+var rollColors = []string{
+	"#e41a1c", "#377eb8", "#4daf4a", "#984ea3",
+	"#ff7f00", "#a65628", "#f781bf", "#999999",
+}
+
+func rollColor(index int) string {
+	return rollColors[index%len(rollColors)]
+}
+
+// The properties of a frame feature. A struct to keep the output
+// order stable.
+type frameProperties struct {
+	Roll         int      `json:"roll"`
+	RollDesc     string   `json:"rollDescription,omitempty"`
+	Frame        int      `json:"frame"`
+	Artists      []string `json:"artists,omitempty"`
+	Camera       string   `json:"camera,omitempty"`
+	Lens         string   `json:"lens,omitempty"`
+	Film         string   `json:"film,omitempty"`
+	Iso          int      `json:"iso,omitempty"`
+	ShutterSpeed string   `json:"shutterSpeed,omitempty"`
+	Aperture     string   `json:"aperture,omitempty"`
+	FocalLength  float64  `json:"focalLength,omitempty"`
+	Time         string   `json:"time,omitempty"`
+	Desc         string   `json:"description,omitempty"`
+	Altitude     float64  `json:"altitude,omitempty"`
+
+	TimeEstimated     bool `json:"timeEstimated,omitempty"`
+	LocationEstimated bool `json:"locationEstimated,omitempty"`
+}
+
+type rollProperties struct {
+	Roll        int     `json:"roll"`
+	RollDesc    string  `json:"rollDescription,omitempty"`
+	Stroke      string  `json:"stroke"`
+	StrokeWidth float64 `json:"stroke-width"`
+}
+
+type geoJsonGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+type geoJsonFeature struct {
+	Type       string          `json:"type"`
+	Geometry   geoJsonGeometry `json:"geometry"`
+	Properties interface{}     `json:"properties"`
+}
+
+type geoJsonFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJsonFeature `json:"features"`
+}
+
+func frameToProperties(frame *e4f.Frame) frameProperties {
+	exp := frame.Exposure
+	props := frameProperties{
+		Roll:         frame.Roll.Id,
+		RollDesc:     frame.Roll.Desc,
+		Frame:        frame.Number(),
+		Artists:      frame.ArtistNames(),
+		Camera:       frame.CameraDescription(),
+		Lens:         frame.LensDescription(),
+		Film:         frame.FilmLabel(),
+		Iso:          frame.Roll.Iso,
+		ShutterSpeed: exp.ShutterSpeed,
+		Aperture:     exp.Aperture,
+		FocalLength:  exp.FocalLength,
+		Desc:         exp.Desc,
+
+		TimeEstimated:     frame.TimeEstimated,
+		LocationEstimated: frame.GpsEstimated,
+	}
+	// In UTC, as the KML time stamp.
+	if t, ok := frameUTCTime(frame); ok {
+		props.Time = t.Format(time.RFC3339)
+	}
+	if frame.Gps != nil {
+		props.Altitude = frame.Gps.Alt
+	}
+	return props
+}
+
+// Return the frames of the roll with a location.
+func locatedFrames(db *e4f.E4fDb, roll *e4f.ExposedRoll) (frames []*e4f.Frame) {
+	for _, frame := range db.FramesForRoll(roll) {
+		if frame.Gps != nil {
+			frames = append(frames, frame)
+		}
+	}
+	return
+}
+
+// Generate a GeoJSON FeatureCollection for the rolls: a Point per
+// located frame, then a LineString per roll, in frame order.
+func rollsToGeoJson(db *e4f.E4fDb, rolls []*e4f.ExposedRoll) geoJsonFeatureCollection {
+	collection := geoJsonFeatureCollection{
+		Type:     "FeatureCollection",
+		Features: []geoJsonFeature{},
+	}
+	for idx, roll := range rolls {
+		var line [][2]float64
+		for _, frame := range locatedFrames(db, roll) {
+			point := [2]float64{frame.Gps.Long, frame.Gps.Lat}
+			line = append(line, point)
+			collection.Features = append(collection.Features,
+				geoJsonFeature{
+					Type:       "Feature",
+					Geometry:   geoJsonGeometry{"Point", point},
+					Properties: frameToProperties(frame),
+				})
+		}
+		if len(line) < 2 {
+			continue
+		}
+		collection.Features = append(collection.Features, geoJsonFeature{
+			Type:     "Feature",
+			Geometry: geoJsonGeometry{"LineString", line},
+			Properties: rollProperties{
+				Roll:        roll.Id,
+				RollDesc:    roll.Desc,
+				Stroke:      rollColor(idx),
+				StrokeWidth: 2,
+			},
+		})
+	}
+	return collection
+}
+
+func writeGeoJson(w io.Writer, db *e4f.E4fDb, rolls []*e4f.ExposedRoll) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(rollsToGeoJson(db, rolls)); err != nil {
+		return fmt.Errorf("GeoJSON: %w", err)
+	}
+	return nil
+}
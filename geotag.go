@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gitlab.com/photo/e4f-go/src/e4f"
+	"gitlab.com/photo/e4f-go/src/gpx"
+)
+
+// Read the timed track points of the GPX files in the comma separated
+// list.
+func readTracks(list string) (points []e4f.TrackPoint, err error) {
+	for _, path := range strings.Split(list, ",") {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		g, err := gpx.Read(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for _, track := range g.Tracks {
+			for _, segment := range track.Segments {
+				for _, pt := range segment.Points {
+					if pt.Time == nil {
+						continue
+					}
+					point := e4f.TrackPoint{
+						Time: pt.Time.UTC(),
+						Lat:  pt.Lat,
+						Long: pt.Lon,
+					}
+					if pt.Ele != nil {
+						point.Alt = *pt.Ele
+					}
+					points = append(points, point)
+				}
+			}
+		}
+	}
+	return
+}
+
+const reportTimeLayout = "2006-01-02T15:04:05Z"
+
+func formatTrackPoint(pt *e4f.TrackPoint) string {
+	return fmt.Sprintf("%s (%.6f, %.6f)", pt.Time.Format(reportTimeLayout),
+		pt.Lat, pt.Long)
+}
+
+// Print what was geotagged, a line per frame.
+func printGeotagReport(w io.Writer, results []e4f.GeotagResult) {
+	for _, result := range results {
+		fmt.Fprintf(w, "Roll %d, frame %d at %s: %.6f, %.6f",
+			result.Roll.Id, result.Frame, result.Time.Format(reportTimeLayout),
+			result.Gps.Lat, result.Gps.Long)
+		if result.After != nil {
+			fmt.Fprintf(w, " interpolated from %s and %s\n",
+				formatTrackPoint(result.Before),
+				formatTrackPoint(result.After))
+		} else {
+			fmt.Fprintf(w, " from %s\n", formatTrackPoint(result.Before))
+		}
+	}
+	fmt.Fprintf(w, "%d frames geotagged\n", len(results))
+}
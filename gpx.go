@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"gitlab.com/photo/e4f-go/src/e4f"
+	"gitlab.com/photo/e4f-go/src/gpx"
+)
+
+// Return the waypoint for the frame, or nil if it has no location.
+func frameToWaypoint(frame *e4f.Frame) *gpx.Waypoint {
+	gps := frame.Gps
+	if gps == nil {
+		return nil
+	}
+	wpt := &gpx.Waypoint{
+		Lat:  gps.Lat,
+		Lon:  gps.Long,
+		Name: fmt.Sprintf("Frame %d", frame.Number()),
+		Desc: frame.Exposure.Desc,
+	}
+	if frame.Roll.Desc != "" {
+		wpt.Name = fmt.Sprintf("%s, frame %d", frame.Roll.Desc,
+			frame.Number())
+	}
+	if frame.Exposure.Desc != "" {
+		wpt.Name += ": " + frame.Exposure.Desc
+	}
+	if gps.Alt != 0 {
+		alt := gps.Alt
+		wpt.Ele = &alt
+	}
+	if t, ok := frameUTCTime(frame); ok {
+		wpt.Time = &t
+	}
+	if frame.GpsEstimated {
+		wpt.Type = "estimated"
+	}
+	return wpt
+}
+
+// Generate the GPX for the rolls: a waypoint per frame, and if
+// withTracks a track per roll, ordered by time.
+func rollsToGpx(db *e4f.E4fDb, rolls []*e4f.ExposedRoll,
+	withTracks bool) *gpx.Gpx {
+
+	g := gpx.New("e4f-go")
+	for _, roll := range rolls {
+		var points []gpx.Waypoint
+		for _, frame := range db.FramesForRoll(roll) {
+			wpt := frameToWaypoint(frame)
+			if wpt == nil {
+				continue
+			}
+			g.Waypoints = append(g.Waypoints, *wpt)
+			if wpt.Time != nil {
+				points = append(points, *wpt)
+			}
+		}
+
+		if withTracks && len(points) > 0 {
+			sort.SliceStable(points, func(i, j int) bool {
+				return points[i].Time.Before(*points[j].Time)
+			})
+			g.Tracks = append(g.Tracks, gpx.Track{
+				Name:     roll.Desc,
+				Segments: []gpx.Segment{{Points: points}},
+			})
+		}
+	}
+	return g
+}
+
+func writeGpx(w io.Writer, db *e4f.E4fDb, rolls []*e4f.ExposedRoll,
+	withTracks bool) error {
+	return rollsToGpx(db, rolls, withTracks).Write(w)
+}
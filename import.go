@@ -0,0 +1,426 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitlab.com/photo/e4f-go/src/e4f"
+	"gitlab.com/photo/e4f-go/src/xmp"
+)
+
+// The values of a frame read back from a sidecar, or from AnalogExif,
+// through the properties of the mapping.
+type sidecarFrame struct {
+	Number           int
+	Description      string
+	Creator          []string
+	DateTimeOriginal string
+	TimeEstimated    bool
+	ISOSpeedRatings  []int
+	ShutterSpeed     string
+	FNumber          float64
+	FocalLength      float64
+	FlashFired       bool
+	MeteringMode     int
+	LightSource      int
+
+	Make                    string
+	Model                   string
+	SerialNumber            string
+	CameraDefaultFilmType   string
+	CameraDefaultFrameCount int
+
+	Lens             string
+	LensInfo         string
+	LensSerialNumber string
+
+	RollId          string
+	RollDescription string
+	RollFrameCount  int
+	RollLoaded      string
+	RollUnloaded    string
+	FilmMaker       string
+	Film            string
+	FilmType        string
+	FilmProcess     string
+	FilmColorType   string
+	FilmSpeed       int
+
+	GPSLatitude       string
+	GPSLongitude      string
+	GPSAltitude       float64
+	GPSAltitudeRef    int
+	LocationEstimated bool
+}
+
+// Return the values of the frame by the name of their source. See
+// frameSources.
+func (s *sidecarFrame) fields() map[string]interface{} {
+	return map[string]interface{}{
+		"number":                  &s.Number,
+		"description":             &s.Description,
+		"artist":                  &s.Creator,
+		"dateTimeOriginal":        &s.DateTimeOriginal,
+		"timeEstimated":           &s.TimeEstimated,
+		"iso":                     &s.ISOSpeedRatings,
+		"shutterSpeed":            &s.ShutterSpeed,
+		"aperture":                &s.FNumber,
+		"focalLength":             &s.FocalLength,
+		"flashFired":              &s.FlashFired,
+		"meteringMode":            &s.MeteringMode,
+		"lightSource":             &s.LightSource,
+		"cameraMake":              &s.Make,
+		"cameraModel":             &s.Model,
+		"cameraSerialNumber":      &s.SerialNumber,
+		"cameraDefaultFilmType":   &s.CameraDefaultFilmType,
+		"cameraDefaultFrameCount": &s.CameraDefaultFrameCount,
+		"lens":                    &s.Lens,
+		"lensInfo":                &s.LensInfo,
+		"lensSerialNumber":        &s.LensSerialNumber,
+		"rollId":                  &s.RollId,
+		"rollDescription":         &s.RollDescription,
+		"rollFrameCount":          &s.RollFrameCount,
+		"rollLoaded":              &s.RollLoaded,
+		"rollUnloaded":            &s.RollUnloaded,
+		"filmMaker":               &s.FilmMaker,
+		"film":                    &s.Film,
+		"filmType":                &s.FilmType,
+		"filmProcess":             &s.FilmProcess,
+		"filmColorType":           &s.FilmColorType,
+		"filmSpeed":               &s.FilmSpeed,
+		"gpsLatitude":             &s.GPSLatitude,
+		"gpsLongitude":            &s.GPSLongitude,
+		"gpsAltitude":             &s.GPSAltitude,
+		"gpsAltitudeRef":          &s.GPSAltitudeRef,
+		"locationEstimated":       &s.LocationEstimated,
+	}
+}
+
+// Parse a number, that can be a rational.
+func parseImportNumber(s string) (float64, error) {
+	if strings.Contains(s, "/") {
+		r, err := xmp.ParseRational(s)
+		if err != nil {
+			return 0, err
+		}
+		return r.Float(), nil
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	return f, nil
+}
+
+// Parse the items of the property p into dst, a field of
+// sidecarFrame.
+func (p *mappingProperty) parse(items []string, dst interface{}) error {
+	number := func(s string) (float64, error) {
+		f, err := parseImportNumber(s)
+		if err == nil && p.Scale != 0 {
+			f /= p.Scale
+		}
+		return f, err
+	}
+	switch d := dst.(type) {
+	case *string:
+		*d = strings.Join(items, "; ")
+	case *[]string:
+		if len(items) == 1 && p.Type != mappingBag &&
+			p.Type != mappingSeq {
+			items = strings.Split(items[0], "; ")
+		}
+		*d = items
+	case *bool:
+		b, err := strconv.ParseBool(items[0])
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", items[0])
+		}
+		*d = b
+	case *int:
+		f, err := number(items[0])
+		if err != nil {
+			return err
+		}
+		*d = int(math.Round(f))
+	case *float64:
+		f, err := number(items[0])
+		if err != nil {
+			return err
+		}
+		*d = f
+	case *[]int:
+		var values []int
+		for _, item := range items {
+			f, err := number(item)
+			if err != nil {
+				return err
+			}
+			values = append(values, int(math.Round(f)))
+		}
+		*d = values
+	}
+	return nil
+}
+
+// Return the items of the property p in x, one for a simple property.
+func (p *mappingProperty) items(x xmp.Xmp) (items []string) {
+	switch p.Type {
+	case mappingBag, mappingSeq:
+		for i := 1; ; i++ {
+			item, found := xmp.GetProperty(x, p.ns,
+				fmt.Sprintf("%s[%d]", p.name, i))
+			if !found {
+				return
+			}
+			items = append(items, item)
+		}
+	case mappingLangAlt:
+		if value, _, found := xmp.GetLocalizedText(x, p.ns, p.name, "",
+			xmp.X_DEFAULT); found {
+			items = []string{value}
+		}
+	default:
+		if value, found := xmp.GetProperty(x, p.ns, p.name); found {
+			items = []string{value}
+		}
+	}
+	return
+}
+
+// Read the properties of the mapping m from x into the frame. A
+// value can't be read back with a format, except as text. A property
+// that can't be parsed is reported with path and skipped.
+func (s *sidecarFrame) read(x xmp.Xmp, m *mapping, path string) {
+	fields := s.fields()
+	// The sources read, the first property found being used.
+	read := make(map[string]bool)
+	readItems := func(p *mappingProperty, items []string) {
+		dst, found := fields[p.Source]
+		if !found || read[p.Source] || len(items) == 0 {
+			return
+		}
+		if _, isText := dst.(*string); p.Format != "" && !isText {
+			return
+		}
+		if err := p.parse(items, dst); err != nil {
+			log.Printf("%s: %s: %s", path, p.Property, err)
+			return
+		}
+		read[p.Source] = true
+	}
+	for i := range m.Properties {
+		p := &m.Properties[i]
+		if p.Type != mappingStruct {
+			readItems(p, p.items(x))
+			continue
+		}
+		for j := range p.Fields {
+			field := &p.Fields[j]
+			if value, found := xmp.GetStructField(x, p.ns, p.name,
+				field.ns, field.name); found {
+				readItems(field, []string{value})
+			}
+		}
+	}
+}
+
+// Layouts of the XMP dates, the e4f timestamps being tried last.
+var importTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	e4f.ExifTimeLayout,
+}
+
+// Convert a XMP date to an e4f timestamp, keeping the wall time.
+func importTime(s string) string {
+	if s == "" {
+		return ""
+	}
+	for _, layout := range importTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return e4f.FormatTime(t)
+		}
+	}
+	if t, err := e4f.ParseTime(s); err == nil {
+		return e4f.FormatTime(t)
+	}
+	return ""
+}
+
+func formatAperture(f float64) string {
+	if f == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// Return the record of the frame in the sidecar. The frames are in the
+// roll of their roll id, else of their roll description, like in
+// AnalogExif, else of their directory dir.
+func (s *sidecarFrame) record(dir string) *e4f.FrameRecord {
+	rec := &e4f.FrameRecord{
+		RollKey:      "dir:" + dir,
+		Number:       s.Number,
+		Desc:         s.Description,
+		ShutterSpeed: s.ShutterSpeed,
+		Aperture:     formatAperture(s.FNumber),
+		FocalLength:  s.FocalLength,
+		FlashOn:      s.FlashFired,
+		MeteringMode: e4f.MeteringModeName(s.MeteringMode),
+		LightSource:  e4f.LightSourceName(s.LightSource),
+
+		CameraMake:              s.Make,
+		CameraModel:             s.Model,
+		CameraSerialNumber:      s.SerialNumber,
+		CameraDefaultFilmType:   s.CameraDefaultFilmType,
+		CameraDefaultFrameCount: s.CameraDefaultFrameCount,
+
+		Lens:             s.Lens,
+		LensSerialNumber: s.LensSerialNumber,
+
+		RollDesc:       s.RollDescription,
+		RollFrameCount: s.RollFrameCount,
+		RollLoaded:     importTime(s.RollLoaded),
+		RollUnloaded:   importTime(s.RollUnloaded),
+		FilmType:       s.FilmType,
+		FilmMake:       s.FilmMaker,
+		Film:           s.Film,
+		FilmProcess:    s.FilmProcess,
+		FilmColorType:  s.FilmColorType,
+		FilmIso:        s.FilmSpeed,
+	}
+	if s.RollId != "" {
+		rec.RollKey = "id:" + s.RollId
+	} else if s.RollDescription != "" {
+		rec.RollKey = "desc:" + s.RollDescription
+	}
+	rec.Artists = s.Creator
+	if len(s.ISOSpeedRatings) > 0 {
+		rec.Iso = s.ISOSpeedRatings[0]
+	}
+	// The estimates aren't data, -estimate makes them again.
+	if !s.TimeEstimated {
+		rec.TimeTaken = importTime(s.DateTimeOriginal)
+	}
+
+	// "min max widest narrowest", like "21/2 35/1 7/2 22/1"
+	if info := strings.Fields(s.LensInfo); len(info) == 4 {
+		var values [4]xmp.Rational
+		var err error
+		for i := range info {
+			if values[i], err = xmp.ParseRational(info[i]); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			rec.LensFocalLengthMin = values[0].Float()
+			rec.LensFocalLengthMax = values[1].Float()
+			rec.LensApertureMin = formatAperture(values[2].Float())
+			rec.LensApertureMax = formatAperture(values[3].Float())
+		}
+	}
+
+	if !s.LocationEstimated && s.GPSLatitude != "" &&
+		s.GPSLongitude != "" {
+		lat, errLat := e4f.ParseGpsCoord(s.GPSLatitude)
+		long, errLong := e4f.ParseGpsCoord(s.GPSLongitude)
+		if errLat == nil && errLong == nil {
+			alt := s.GPSAltitude
+			if s.GPSAltitudeRef == 1 {
+				alt = -alt
+			}
+			rec.Gps = &e4f.GpsLocation{Lat: lat, Long: long, Alt: alt}
+		}
+	}
+	return rec
+}
+
+// Whether the sidecar has a frame: a roll, a film or a frame number.
+func (s *sidecarFrame) isFrame() bool {
+	return s.RollId != "" || s.RollDescription != "" || s.Film != "" ||
+		s.Number != 0
+}
+
+// Read the XMP of path, a sidecar or a tagged scan. Return nil if
+// there is none, or if the scan has a sidecar to be read instead.
+func readImportXmp(path string) xmp.Xmp {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".xmp" {
+		buffer, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("Can't read %s: %s", path, err)
+			return nil
+		}
+		return xmp.New(buffer)
+	}
+	if !scanExtensions[ext] || sidecarPath(path) != "" {
+		return nil
+	}
+	return readScanXmp(path)
+}
+
+// Return the record of the frame in x, read from path with the
+// mapping m. Return nil if there is none.
+func importFrame(x xmp.Xmp, m *mapping, path string) *e4f.FrameRecord {
+	var frame sidecarFrame
+	frame.read(x, m, path)
+	if !frame.isFrame() {
+		return nil
+	}
+	return frame.record(filepath.Dir(path))
+}
+
+// Rebuild the rolls from the sidecars and the tagged scans in dir and
+// its subdirectories, with the properties of the mapping. See record
+// for the rolls. A frame found in several files, like NAME.jpg and
+// NAME.tif, is imported from the first one.
+func importScans(dir string) (*e4f.E4fDb, error) {
+	var records []*e4f.FrameRecord
+	// The file of each frame, by roll key and number.
+	type frameKey struct {
+		roll   string
+		number int
+	}
+	imported := make(map[frameKey]string)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry,
+		err error) error {
+
+		if err != nil || d.IsDir() {
+			return err
+		}
+		x := readImportXmp(path)
+		if x == nil {
+			return nil
+		}
+		defer xmp.Free(x)
+
+		rec := importFrame(x, options.Mapping, path)
+		if rec == nil {
+			return nil
+		}
+		if rec.Number != 0 {
+			key := frameKey{rec.RollKey, rec.Number}
+			if first, found := imported[key]; found {
+				log.Printf("%s: frame %d already imported from %s",
+					path, rec.Number, first)
+				return nil
+			}
+			imported[key] = path
+		}
+		records = append(records, rec)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return e4f.Rebuild(records), nil
+}
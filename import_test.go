@@ -0,0 +1,147 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gitlab.com/photo/e4f-go/src/e4f"
+	"gitlab.com/photo/e4f-go/src/xmp"
+)
+
+// Write the frame with the mapping m and read it back.
+func reimport(t *testing.T, m *mapping, frame *e4f.Frame,
+	edit func(x xmp.Xmp)) *e4f.FrameRecord {
+
+	x := xmp.NewEmpty()
+	defer xmp.Free(x)
+	m.apply(x, frame)
+	if edit != nil {
+		edit(x)
+	}
+	rec := importFrame(x, m, "scans/roll/frame.xmp")
+	if rec == nil {
+		t.Fatalf("Frame %d not imported", frame.Number())
+	}
+	return rec
+}
+
+func TestImportFrame(t *testing.T) {
+	options.Mapping = defaultMapping()
+	db := e4f.Parse("samples/export-Roll-20130630_203650.xml")
+	roll := db.ExposedRolls[0]
+	roll.Desc = "Montreal"
+	exp := db.ExposuresForRoll(roll.Id)[0]
+	frame := db.Frame(roll, exp, 0)
+
+	rec := reimport(t, options.Mapping, frame, nil)
+	// Written without time zone.
+	if rec.Number != 1 || rec.RollDesc != "Montreal" ||
+		rec.TimeTaken != exp.TimeTaken ||
+		rec.FocalLength != exp.FocalLength ||
+		rec.ShutterSpeed != exp.ShutterSpeed {
+		t.Errorf("Imported %+v", rec)
+	}
+
+	// Another roll with the same description.
+	other := *roll
+	other.Id = roll.Id + 1
+	otherRec := reimport(t, options.Mapping,
+		db.Frame(&other, exp, 0), nil)
+	if otherRec.RollKey == rec.RollKey {
+		t.Errorf("Rolls %d and %d both imported as %q", roll.Id,
+			other.Id, rec.RollKey)
+	}
+
+	// A property that can't be read is skipped.
+	bad := reimport(t, options.Mapping, frame, func(x xmp.Xmp) {
+		xmp.SetProperty(x, xmp.NS_EXIF, "FocalLength", "long", 0)
+	})
+	if bad.FocalLength != 0 || bad.RollKey != rec.RollKey ||
+		bad.ShutterSpeed != exp.ShutterSpeed {
+		t.Errorf("Imported %+v", bad)
+	}
+
+	// Without roll id, the frames are in the roll of their
+	// description.
+	described := reimport(t, options.Mapping, frame, func(x xmp.Xmp) {
+		ns, _ := xmp.PrefixNamespace("e4f")
+		xmp.DeleteProperty(x, ns, "RollId")
+	})
+	if described.RollKey == rec.RollKey ||
+		described.RollKey == "dir:scans/roll" {
+		t.Errorf("Imported in roll %q", described.RollKey)
+	}
+}
+
+func TestImportMapping(t *testing.T) {
+	m, err := parseMapping([]byte(`{
+  "properties": [
+    { "property": "dc:title", "type": "lang-alt", "source": "description" },
+    { "property": "dc:source", "source": "rollId" },
+    { "property": "dc:format", "source": "number", "format": "#%d" },
+    { "property": "dc:subject", "type": "bag", "source": "artist" }
+  ]
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := &e4f.Frame{
+		Index:    2,
+		Exposure: &e4f.Exposure{Desc: "Harbour"},
+		Roll:     &e4f.ExposedRoll{Id: 7},
+		Artists:  []*e4f.Artist{{Name: "Ada"}, {Name: "Grace"}},
+	}
+	rec := reimport(t, m, frame, nil)
+	if rec.Desc != "Harbour" || rec.RollKey != "id:7" ||
+		len(rec.Artists) != 2 || rec.Artists[1] != "Grace" {
+		t.Errorf("Imported %+v", rec)
+	}
+	// Not read back with its format.
+	if rec.Number != 0 {
+		t.Errorf("Imported number %d", rec.Number)
+	}
+}
+
+// A frame in several files is imported once, and only the scans and
+// the sidecars are read.
+func TestImportScans(t *testing.T) {
+	options.Mapping = defaultMapping()
+	db := e4f.Parse("samples/export-Roll-20130630_203650.xml")
+	roll := db.ExposedRolls[0]
+	exps := db.ExposuresForRoll(roll.Id)
+
+	dir := t.TempDir()
+	for scan, index := range map[string]int{
+		"IMG_1.jpg": 0,
+		"IMG_1.tif": 0,
+		"IMG_2.jpg": 1,
+	} {
+		x := xmp.NewEmpty()
+		options.Mapping.apply(x, db.Frame(roll, exps[index], index))
+		err := writeSidecar(filepath.Join(dir, scan+".xmp"), x)
+		xmp.Free(x)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Not a scan.
+	err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("Roll 1"),
+		0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imported, err := importScans(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imported.ExposedRolls) != 1 {
+		t.Fatalf("%d rolls imported", len(imported.ExposedRolls))
+	}
+	rebuilt := imported.ExposuresForRoll(imported.ExposedRolls[0].Id)
+	if len(rebuilt) != 2 || rebuilt[0].Number != 1 ||
+		rebuilt[1].Number != 2 {
+		t.Errorf("Imported %d exposures", len(rebuilt))
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitlab.com/photo/e4f-go/src/e4f"
+)
+
+const kmlNamespace = "http://www.opengis.net/kml/2.2"
+
+type kmlLineStyle struct {
+	Color string  `xml:"color"`
+	Width float64 `xml:"width"`
+}
+
+type kmlIconStyle struct {
+	Color string `xml:"color"`
+}
+
+type kmlStyle struct {
+	Id        string       `xml:"id,attr"`
+	IconStyle kmlIconStyle `xml:"IconStyle"`
+	LineStyle kmlLineStyle `xml:"LineStyle"`
+}
+
+type kmlData struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value"`
+}
+
+type kmlPoint struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+type kmlLineString struct {
+	Tessellate  int    `xml:"tessellate"`
+	Coordinates string `xml:"coordinates"`
+}
+
+type kmlPlacemark struct {
+	Name         string         `xml:"name"`
+	Description  string         `xml:"description,omitempty"`
+	TimeStamp    string         `xml:"TimeStamp>when,omitempty"`
+	StyleUrl     string         `xml:"styleUrl"`
+	ExtendedData []kmlData      `xml:"ExtendedData>Data,omitempty"`
+	Point        *kmlPoint      `xml:"Point"`
+	LineString   *kmlLineString `xml:"LineString"`
+}
+
+type kmlFolder struct {
+	Name       string         `xml:"name"`
+	Placemarks []kmlPlacemark `xml:"Placemark"`
+}
+
+type kmlDocument struct {
+	XMLName xml.Name    `xml:"kml"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	Name    string      `xml:"Document>name"`
+	Styles  []kmlStyle  `xml:"Document>Style"`
+	Folders []kmlFolder `xml:"Document>Folder"`
+}
+
+// Convert a "#rrggbb" color to the KML "aabbggrr".
+func kmlColor(color string) string {
+	return "ff" + color[5:7] + color[3:5] + color[1:3]
+}
+
+// The KML coordinates of gps. Never in exponent notation, that KML
+// doesn't allow.
+func kmlCoordinates(gps *e4f.GpsLocation) string {
+	var coords []string
+	for _, v := range []float64{gps.Long, gps.Lat, gps.Alt} {
+		coords = append(coords, strconv.FormatFloat(v, 'f', -1, 64))
+	}
+	return strings.Join(coords, ",")
+}
+
+func frameToKmlData(frame *e4f.Frame) (data []kmlData) {
+	props := frameToProperties(frame)
+	add := func(name string, value string) {
+		if value != "" {
+			data = append(data, kmlData{name, value})
+		}
+	}
+	addInt := func(name string, value int) {
+		if value != 0 {
+			add(name, fmt.Sprint(value))
+		}
+	}
+	addInt("roll", props.Roll)
+	addInt("frame", props.Frame)
+	add("artists", strings.Join(props.Artists, ", "))
+	add("camera", props.Camera)
+	add("lens", props.Lens)
+	add("film", props.Film)
+	addInt("iso", props.Iso)
+	add("shutterSpeed", props.ShutterSpeed)
+	add("aperture", props.Aperture)
+	if props.FocalLength != 0 {
+		add("focalLength", fmt.Sprint(props.FocalLength))
+	}
+	if props.TimeEstimated {
+		add("timeEstimated", "true")
+	}
+	if props.LocationEstimated {
+		add("locationEstimated", "true")
+	}
+	return
+}
+
+// Generate the KML for the rolls: a folder per roll, with a
+// placemark per located frame and the path of the roll.
+func rollsToKml(db *e4f.E4fDb, rolls []*e4f.ExposedRoll) kmlDocument {
+	doc := kmlDocument{Xmlns: kmlNamespace, Name: "e4f-go"}
+	for idx, roll := range rolls {
+		styleId := fmt.Sprintf("roll-%d", roll.Id)
+		color := kmlColor(rollColor(idx))
+		doc.Styles = append(doc.Styles, kmlStyle{
+			Id:        styleId,
+			IconStyle: kmlIconStyle{color},
+			LineStyle: kmlLineStyle{color, 2},
+		})
+
+		folder := kmlFolder{Name: roll.Desc}
+		if folder.Name == "" {
+			folder.Name = fmt.Sprintf("Roll %d", roll.Id)
+		}
+		var line []string
+		for _, frame := range locatedFrames(db, roll) {
+			placemark := kmlPlacemark{
+				Name:         fmt.Sprintf("Frame %d", frame.Number()),
+				Description:  frame.Exposure.Desc,
+				StyleUrl:     "#" + styleId,
+				ExtendedData: frameToKmlData(frame),
+				Point:        &kmlPoint{kmlCoordinates(frame.Gps)},
+			}
+			if t, ok := frameUTCTime(frame); ok {
+				placemark.TimeStamp = t.Format(time.RFC3339)
+			}
+			folder.Placemarks = append(folder.Placemarks, placemark)
+			line = append(line, kmlCoordinates(frame.Gps))
+		}
+		if len(line) >= 2 {
+			folder.Placemarks = append(folder.Placemarks, kmlPlacemark{
+				Name:     folder.Name,
+				StyleUrl: "#" + styleId,
+				LineString: &kmlLineString{
+					Tessellate:  1,
+					Coordinates: strings.Join(line, " "),
+				},
+			})
+		}
+		doc.Folders = append(doc.Folders, folder)
+	}
+	return doc
+}
+
+func writeKml(w io.Writer, db *e4f.E4fDb, rolls []*e4f.ExposedRoll) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(rollsToKml(db, rolls)); err != nil {
+		return fmt.Errorf("KML: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
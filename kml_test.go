@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+
+	"gitlab.com/photo/e4f-go/src/e4f"
+)
+
+func TestKmlCoordinates(t *testing.T) {
+	for _, test := range []struct {
+		gps      e4f.GpsLocation
+		expected string
+	}{
+		{e4f.GpsLocation{Long: -73.5673, Lat: 45.5017, Alt: 35},
+			"-73.5673,45.5017,35"},
+		{e4f.GpsLocation{Long: 0.00001, Lat: -0.000002, Alt: 1e21},
+			"0.00001,-0.000002,1000000000000000000000"},
+		{e4f.GpsLocation{}, "0,0,0"},
+	} {
+		if s := kmlCoordinates(&test.gps); s != test.expected {
+			t.Errorf("%+v: %q, expected %q", test.gps, s, test.expected)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"gitlab.com/photo/e4f-go/src/e4f"
+)
+
+// Lint the rolls and print the findings, as text or, if asJson, as a
+// JSON array. Return true if there is an error.
+func lintRolls(w io.Writer, db *e4f.E4fDb, rolls []*e4f.ExposedRoll,
+	asJson bool) (failed bool, err error) {
+
+	findings := []e4f.Finding{}
+	for _, roll := range rolls {
+		findings = append(findings, db.LintRoll(roll)...)
+	}
+	for _, finding := range findings {
+		if finding.Severity == e4f.SeverityError {
+			failed = true
+		}
+	}
+
+	if asJson {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(findings)
+		return
+	}
+	for _, finding := range findings {
+		if _, err = fmt.Fprintln(w, finding); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gitlab.com/photo/e4f-go/src/e4f"
+	"gitlab.com/photo/e4f-go/src/xmp"
+)
+
+// The XMP property types of the mapping.
+const (
+	mappingSimple  = "simple"
+	mappingBag     = "bag"
+	mappingSeq     = "seq"
+	mappingLangAlt = "lang-alt"
+	mappingStruct  = "struct"
+)
+
+// A namespace to register for the mapping.
+type mappingNamespace struct {
+	Prefix string `json:"prefix"`
+	URI    string `json:"uri"`
+}
+
+// A XMP property and where its value comes from.
+type mappingProperty struct {
+	// Qualified name, like "dc:description".
+	Property string `json:"property"`
+	// One of the mapping types. Default is simple.
+	Type string `json:"type,omitempty"`
+	// Name of the frame source. See frameSources.
+	Source string `json:"source,omitempty"`
+	// fmt format of the value. Default is %v, and True or False for
+	// booleans.
+	Format string `json:"format,omitempty"`
+	// Multiply numbers by it before formatting, if not 0.
+	Scale float64 `json:"scale,omitempty"`
+	// Write numbers as a rational, like "7/2", if true.
+	Rational bool `json:"rational,omitempty"`
+	// The fields of a struct.
+	Fields []mappingProperty `json:"fields,omitempty"`
+
+	ns   xmp.Namespace
+	name string
+}
+
+// How the frames are written to XMP.
+type mapping struct {
+	Namespaces []mappingNamespace `json:"namespaces,omitempty"`
+	Properties []mappingProperty  `json:"properties"`
+}
+
+//go:embed mapping.json
+var defaultMappingJson []byte
+
+// Parse and check the mapping, registering its namespaces.
+func parseMapping(data []byte) (*mapping, error) {
+	m := &mapping{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	for _, ns := range m.Namespaces {
+		if ns.Prefix == "" || ns.URI == "" {
+			return nil, fmt.Errorf("namespace needs a prefix and an uri")
+		}
+		if !xmp.RegisterPrefix(ns.Prefix, ns.URI) {
+			return nil, fmt.Errorf("can't register namespace %q as %q",
+				ns.URI, ns.Prefix)
+		}
+	}
+	for i := range m.Properties {
+		if err := m.Properties[i].resolve(true); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Load the mapping from a JSON file.
+func loadMapping(path string) (*mapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m, err := parseMapping(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+// Return the built-in mapping.
+func defaultMapping() *mapping {
+	m, err := parseMapping(defaultMappingJson)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Resolve the namespace and check the property.
+func (p *mappingProperty) resolve(topLevel bool) error {
+	prefix, name, found := strings.Cut(p.Property, ":")
+	if !found || name == "" {
+		return fmt.Errorf("property %q isn't prefix:name", p.Property)
+	}
+	ns, found := xmp.PrefixNamespace(prefix)
+	if !found {
+		return fmt.Errorf("property %q: unknown prefix", p.Property)
+	}
+	p.ns, p.name = ns, name
+
+	switch p.Type {
+	case "", mappingSimple, mappingBag, mappingSeq, mappingLangAlt:
+		if _, found := frameSources[p.Source]; !found {
+			return fmt.Errorf("property %q: unknown source %q",
+				p.Property, p.Source)
+		}
+	case mappingStruct:
+		if !topLevel {
+			return fmt.Errorf("property %q: nested struct", p.Property)
+		}
+		for i := range p.Fields {
+			if err := p.Fields[i].resolve(false); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("property %q: unknown type %q", p.Property,
+			p.Type)
+	}
+	return nil
+}
+
+// Whether the property, or a field of it, is from -rights.
+func (p *mappingProperty) fromRights() bool {
+	if rightsSources[p.Source] {
+		return true
+	}
+	for i := range p.Fields {
+		if p.Fields[i].fromRights() {
+			return true
+		}
+	}
+	return false
+}
+
+// Format the value of the source for the property.
+func (p *mappingProperty) format(value interface{}) string {
+	if p.Scale != 0 {
+		switch v := value.(type) {
+		case int:
+			value = float64(v) * p.Scale
+		case float64:
+			value = v * p.Scale
+		}
+	}
+	if p.Format != "" {
+		return fmt.Sprintf(p.Format, value)
+	}
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return "True"
+		}
+		return "False"
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case []string:
+		return strings.Join(v, "; ")
+	case int:
+		if p.Rational {
+			return xmp.Rational{Num: int64(v), Den: 1}.String()
+		}
+	case float64:
+		if p.Rational {
+			return xmp.FloatRational(v).String()
+		}
+	}
+	return fmt.Sprint(value)
+}
+
+// Set the simple property to the value of the source, with the typed
+// setter for it.
+func (p *mappingProperty) set(x xmp.Xmp, value interface{}) {
+	if p.Format == "" && p.Scale == 0 && !p.Rational {
+		switch v := value.(type) {
+		case bool:
+			xmp.SetPropertyBool(x, p.ns, p.name, v, 0)
+			return
+		case int:
+			xmp.SetPropertyInt64(x, p.ns, p.name, int64(v), 0)
+			return
+		case float64:
+			xmp.SetPropertyFloat(x, p.ns, p.name, v, 0)
+			return
+		case time.Time:
+			xmp.SetPropertyDate(x, p.ns, p.name, v, 0)
+			return
+		}
+	}
+	xmp.SetProperty(x, p.ns, p.name, p.format(value), 0)
+}
+
+// Return the formatted value of the property for the frame. ok is
+// false if there is none.
+func (p *mappingProperty) value(frame *e4f.Frame) (string, bool) {
+	value, ok := frameSources[p.Source](frame)
+	if !ok {
+		return "", false
+	}
+	return p.format(value), true
+}
+
+// Write the property of the frame into x.
+func (p *mappingProperty) apply(x xmp.Xmp, frame *e4f.Frame) {
+	switch p.Type {
+	case mappingStruct:
+		for i := range p.Fields {
+			field := &p.Fields[i]
+			if value, ok := field.value(frame); ok {
+				xmp.SetStructField(x, p.ns, p.name, field.ns,
+					field.name, value, 0)
+			}
+		}
+		return
+	}
+
+	value, ok := frameSources[p.Source](frame)
+	if !ok {
+		return
+	}
+	switch p.Type {
+	case mappingBag, mappingSeq:
+		items, isList := value.([]string)
+		if !isList {
+			items = []string{p.format(value)}
+		}
+		for _, item := range items {
+			if p.Type == mappingBag {
+				xmp.AppendArrayItem(x, p.ns, p.name,
+					xmp.PROP_VALUE_IS_ARRAY, item, 0)
+			} else {
+				xmp.AppendArrayItem(x, p.ns, p.name,
+					xmp.PROP_ARRAY_IS_ORDERED, item, 0)
+			}
+		}
+	case mappingLangAlt:
+		xmp.SetLocalizedText(x, p.ns, p.name, "", xmp.X_DEFAULT,
+			p.format(value), 0)
+	default:
+		p.set(x, value)
+	}
+}
+
+// Write the frame into x.
+func (m *mapping) apply(x xmp.Xmp, frame *e4f.Frame) {
+	for i := range m.Properties {
+		m.Properties[i].apply(x, frame)
+	}
+}
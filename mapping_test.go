@@ -0,0 +1,146 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.com/photo/e4f-go/src/e4f"
+	"gitlab.com/photo/e4f-go/src/xmp"
+)
+
+func TestParseMapping(t *testing.T) {
+	if m := defaultMapping(); len(m.Properties) == 0 {
+		t.Error("Empty built-in mapping")
+	}
+
+	for _, test := range []struct {
+		json, err string
+	}{
+		{`{"properties": [{"property": "description"}]}`, "isn't prefix:name"},
+		{`{"properties": [{"property": "nope:title"}]}`, "unknown prefix"},
+		{`{"properties": [{"property": "dc:title", "source": "nope"}]}`,
+			"unknown source"},
+		{`{"properties": [{"property": "dc:title", "type": "nope",
+			"source": "description"}]}`, "unknown type"},
+		{`{"properties": [{"property": "dc:title", "type": "struct",
+			"fields": [{"property": "dc:a", "type": "struct"}]}]}`,
+			"nested struct"},
+		{`{"properties": [{"property": "dc:title", "type": "struct",
+			"fields": [{"property": "dc:a", "source": "nope"}]}]}`,
+			"unknown source"},
+		{`{"namespaces": [{"prefix": "test"}], "properties": []}`,
+			"needs a prefix and an uri"},
+		{`{"properties": {}}`, "cannot unmarshal"},
+	} {
+		_, err := parseMapping([]byte(test.json))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: error %v, expected %q", test.json, err,
+				test.err)
+		}
+	}
+}
+
+func TestMappingFormat(t *testing.T) {
+	date := time.Date(2013, 6, 30, 17, 51, 53, 0,
+		time.FixedZone("EDT", -4*3600))
+	for _, test := range []struct {
+		property mappingProperty
+		value    interface{}
+		expected string
+	}{
+		{mappingProperty{}, "Tri-X", "Tri-X"},
+		{mappingProperty{}, 400, "400"},
+		{mappingProperty{}, 3.5, "3.5"},
+		{mappingProperty{}, true, "True"},
+		{mappingProperty{}, false, "False"},
+		{mappingProperty{}, date, "2013-06-30T17:51:53-04:00"},
+		{mappingProperty{}, []string{"Ada", "Grace"}, "Ada; Grace"},
+		{mappingProperty{Rational: true}, 50, "50/1"},
+		{mappingProperty{Rational: true}, 3.5, "7/2"},
+		{mappingProperty{Format: "f/%.1f"}, 3.5, "f/3.5"},
+		{mappingProperty{Scale: 10, Format: "%.0f"}, 72, "720"},
+		{mappingProperty{Scale: 0.5, Rational: true}, 3.5, "7/4"},
+	} {
+		if s := test.property.format(test.value); s != test.expected {
+			t.Errorf("%+v of %v: %q, expected %q", test.property,
+				test.value, s, test.expected)
+		}
+	}
+}
+
+func TestMappingApply(t *testing.T) {
+	m, err := parseMapping([]byte(`{
+  "namespaces": [
+    { "prefix": "test", "uri": "http://example.com/test/1.0/" }
+  ],
+  "properties": [
+    { "property": "test:Number", "source": "number" },
+    { "property": "test:Aperture", "source": "aperture", "rational": true },
+    { "property": "test:FNumber", "source": "aperture", "format": "f/%.1f" },
+    { "property": "test:Estimated", "source": "timeEstimated" },
+    { "property": "test:Title", "type": "lang-alt", "source": "description" },
+    { "property": "test:Artists", "type": "seq", "source": "artist" },
+    { "property": "test:Keywords", "type": "bag", "source": "description" },
+    { "property": "test:Missing", "source": "shutterSpeed" },
+    { "property": "test:Camera", "type": "struct", "fields": [
+      { "property": "test:Description", "source": "description" },
+      { "property": "test:Serial", "source": "cameraSerialNumber" }
+    ] }
+  ]
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns, _ := xmp.PrefixNamespace("test")
+
+	frame := &e4f.Frame{
+		Index:    2,
+		Exposure: &e4f.Exposure{Desc: "Harbour", Aperture: "3.5"},
+		Roll:     &e4f.ExposedRoll{},
+		Camera:   &e4f.Camera{},
+		Artists:  []*e4f.Artist{{Name: "Ada"}, {Name: "Grace"}},
+
+		TimeEstimated: true,
+	}
+	x := xmp.NewEmpty()
+	defer xmp.Free(x)
+	m.apply(x, frame)
+
+	if n, _ := xmp.GetPropertyInt64(x, ns, "Number"); n != 3 {
+		t.Errorf("Number %d", n)
+	}
+	if estimated, _ := xmp.GetPropertyBool(x, ns, "Estimated"); !estimated {
+		t.Error("Not estimated")
+	}
+	if title, _, _ := xmp.GetLocalizedText(x, ns, "Title", "",
+		xmp.X_DEFAULT); title != "Harbour" {
+		t.Errorf("Title %q", title)
+	}
+	for name, expected := range map[string]string{
+		"Aperture":   "7/2",
+		"FNumber":    "f/3.5",
+		"Artists[1]": "Ada",
+		"Artists[2]": "Grace",
+		// A value that isn't a list is one item.
+		"Keywords[1]": "Harbour",
+	} {
+		if value, _ := xmp.GetProperty(x, ns, name); value != expected {
+			t.Errorf("%s is %q, expected %q", name, value, expected)
+		}
+	}
+	for _, name := range []string{"Missing", "Artists[3]", "Keywords[2]"} {
+		if value, found := xmp.GetProperty(x, ns, name); found {
+			t.Errorf("%s is %q", name, value)
+		}
+	}
+	if value, _ := xmp.GetStructField(x, ns, "Camera", ns,
+		"Description"); value != "Harbour" {
+		t.Errorf("Camera description %q", value)
+	}
+	// No camera serial number.
+	if value, found := xmp.GetStructField(x, ns, "Camera", ns,
+		"Serial"); found {
+		t.Errorf("Camera serial number %q", value)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"gitlab.com/photo/e4f-go/src/e4f"
+)
+
+const timelineLayout = "2006-01-02 15:04"
+
+func rollLabel(roll *e4f.ExposedRoll) string {
+	if roll.Desc == "" {
+		return fmt.Sprintf("roll %d", roll.Id)
+	}
+	return fmt.Sprintf("roll %d %q", roll.Id, roll.Desc)
+}
+
+// Print the rolls of each camera, the overlaps and the gaps.
+func printCameraTimelines(w io.Writer, db *e4f.E4fDb) {
+	for _, timeline := range db.CameraTimelines() {
+		camera := timeline.Camera
+		name := camera.Title
+		if mk, found := db.MakeMap[camera.MakeId]; found &&
+			!strings.HasPrefix(name, mk.Name) {
+			name = mk.Name + " " + name
+		}
+		fmt.Fprintf(w, "Camera %d, %s:\n", camera.Id, name)
+
+		for _, o := range timeline.Rolls {
+			unloaded := "now"
+			if !o.Unloaded.IsZero() {
+				unloaded = o.Unloaded.Format(timelineLayout)
+			}
+			fmt.Fprintf(w, "\t%s to %s: %s\n",
+				o.Loaded.Format(timelineLayout), unloaded,
+				rollLabel(o.Roll))
+		}
+		for _, overlap := range timeline.Overlaps {
+			fmt.Fprintf(w, "\tOverlap %s to %s: %s and %s\n",
+				overlap.Start.Format(timelineLayout),
+				overlap.End.Format(timelineLayout),
+				rollLabel(overlap.First.Roll),
+				rollLabel(overlap.Second.Roll))
+			for _, d := range overlap.Disputed {
+				fmt.Fprintf(w, "\t\tRoll %d, frame %d at %s",
+					d.Roll.Id, d.Frame, d.Time.Format(timelineLayout))
+				if d.Owner != d.Roll {
+					fmt.Fprintf(w, ": likely %s", rollLabel(d.Owner))
+				}
+				fmt.Fprintln(w)
+			}
+		}
+		for _, gap := range timeline.Gaps {
+			fmt.Fprintf(w, "\tEmpty %s to %s, %s\n",
+				gap.Before.Unloaded.Format(timelineLayout),
+				gap.After.Loaded.Format(timelineLayout),
+				gap.After.Loaded.Sub(gap.Before.Unloaded).Round(time.Minute))
+		}
+	}
+}
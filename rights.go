@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gitlab.com/photo/e4f-go/src/e4f"
+)
+
+// The creator contact info, the Iptc4xmpCore:CreatorContactInfo fields.
+type contactInfo struct {
+	Address    string `json:"address,omitempty"`
+	City       string `json:"city,omitempty"`
+	Region     string `json:"region,omitempty"`
+	PostalCode string `json:"postalCode,omitempty"`
+	Country    string `json:"country,omitempty"`
+	Phone      string `json:"phone,omitempty"`
+	Email      string `json:"email,omitempty"`
+	Url        string `json:"url,omitempty"`
+}
+
+// The rights of the frames of an artist. "{year}" in the text is
+// replaced by the year the frame was taken.
+type rights struct {
+	// dc:rights, like "© {year} Jane Doe".
+	Copyright string `json:"copyright,omitempty"`
+	// xmpRights:Marked, true for rights-managed, false for public
+	// domain.
+	Marked       *bool  `json:"marked,omitempty"`
+	UsageTerms   string `json:"usageTerms,omitempty"`
+	WebStatement string `json:"webStatement,omitempty"`
+	// The Creative Commons license URL, cc:license.
+	License string       `json:"license,omitempty"`
+	Contact *contactInfo `json:"contact,omitempty"`
+}
+
+// The rights by artist name.
+type rightsConfig map[string]*rights
+
+// Load the rights from a JSON file.
+func loadRights(path string) (rightsConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := rightsConfig{}
+	if err = json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return config, nil
+}
+
+// Return the rights of the main artist of the frame, or nil.
+func frameRights(f *e4f.Frame) *rights {
+	names := f.ArtistNames()
+	if len(names) == 0 {
+		return nil
+	}
+	return options.Rights[names[0]]
+}
+
+// Replace "{year}" in s with the year of the frame. ok is false if it
+// is needed and unknown.
+func expandYear(s string, f *e4f.Frame) (string, bool) {
+	if !strings.Contains(s, "{year}") {
+		return s, true
+	}
+	t, ok := f.Time()
+	if !ok {
+		return "", false
+	}
+	return strings.ReplaceAll(s, "{year}", strconv.Itoa(t.Year())), true
+}
+
+// Return the frame source for a text of the rights.
+func rightsField(field func(r *rights) string) frameSource {
+	return func(f *e4f.Frame) (interface{}, bool) {
+		r := frameRights(f)
+		if r == nil || field(r) == "" {
+			return nil, false
+		}
+		s, ok := expandYear(field(r), f)
+		if !ok {
+			reportOnce(fmt.Errorf("%q: unknown year, not written", field(r)))
+			return nil, false
+		}
+		return s, true
+	}
+}
+
+// Return the frame source for a field of the contact info.
+func contactField(field func(c *contactInfo) string) frameSource {
+	return rightsField(func(r *rights) string {
+		if r.Contact == nil {
+			return ""
+		}
+		return field(r.Contact)
+	})
+}
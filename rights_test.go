@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+
+	"gitlab.com/photo/e4f-go/src/e4f"
+	"gitlab.com/photo/e4f-go/src/xmp"
+)
+
+// Without -rights, the rights of a scan are left as they are.
+func TestUpdateScanXmpRights(t *testing.T) {
+	options.Mapping = defaultMapping()
+	db := e4f.Parse("samples/export-Roll-20130630_203650.xml")
+	roll := db.ExposedRolls[0]
+	exp := db.ExposuresForRoll(roll.Id)[0]
+	frame := db.Frame(roll, exp, 0)
+	frame.Artists = []*e4f.Artist{{Name: "Jane Doe"}}
+
+	owns := func(prefix, name string) bool {
+		for _, prop := range ownedProperties() {
+			p, _ := xmp.NamespacePrefix(prop.ns)
+			if p == prefix && prop.name == name {
+				return true
+			}
+		}
+		return false
+	}
+	update := func() string {
+		x := xmp.NewEmpty()
+		defer xmp.Free(x)
+		xmp.SetLocalizedText(x, xmp.NS_DC, "rights", "", xmp.X_DEFAULT,
+			"© Lightroom", 0)
+		updateScanXmp(x, frame, scannerInfo{}, scannerMove)
+		rights, _, _ := xmp.GetLocalizedText(x, xmp.NS_DC, "rights", "",
+			xmp.X_DEFAULT)
+		return rights
+	}
+
+	if owns("dc", "rights") || !owns("dc", "creator") {
+		t.Error("Rights owned without -rights")
+	}
+	if rights := update(); rights != "© Lightroom" {
+		t.Errorf("Rights %q", rights)
+	}
+
+	options.Rights = rightsConfig{
+		"Jane Doe": {Copyright: "© {year} Jane Doe"},
+	}
+	defer func() { options.Rights = nil }()
+	if !owns("dc", "rights") {
+		t.Error("Rights not owned with -rights")
+	}
+	if rights := update(); rights != "© 2013 Jane Doe" {
+		t.Errorf("Rights %q", rights)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"gitlab.com/photo/e4f-go/src/e4f"
+	"gitlab.com/photo/e4f-go/src/exif"
+	"gitlab.com/photo/e4f-go/src/xmp"
+)
+
+// What to do with the scanner identity and the scan time found in a
+// scan, that the camera and the shooting time would replace.
+type scannerPolicy string
+
+const (
+	// Move the scanner to the AnalogExif scanner properties and the
+	// scan time to DateTimeDigitized.
+	scannerMove scannerPolicy = "move"
+	// Keep the scanner and the scan time. The camera and the
+	// shooting time aren't written.
+	scannerKeep scannerPolicy = "keep"
+	// Overwrite with the camera and the shooting time.
+	scannerOverwrite scannerPolicy = "overwrite"
+)
+
+func parseScannerPolicy(s string) (scannerPolicy, error) {
+	switch policy := scannerPolicy(s); policy {
+	case scannerMove, scannerKeep, scannerOverwrite:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown scanner policy %q", s)
+}
+
+// The digitization provenance found in a scan.
+type scannerInfo struct {
+	Make  string
+	Model string
+	// The scan time, as found in the XMP and in the EXIF.
+	XmpDateTime  string
+	ExifDateTime string
+	// Whether the EXIF already has DateTimeDigitized.
+	exifDigitized bool
+}
+
+// The properties of a scan the scanner can have set.
+type scanXmp struct {
+	Make             string `xmp:"tiff:Make,omitempty"`
+	Model            string `xmp:"tiff:Model,omitempty"`
+	DateTimeOriginal string `xmp:"exif:DateTimeOriginal,omitempty"`
+}
+
+// Read the scanner from the existing XMP x, that can be nil, and the
+// EXIF of scan. What matches the frame isn't from the scanner and is
+// ignored.
+func readScanner(scan string, x xmp.Xmp, frame *e4f.Frame) (s scannerInfo) {
+	if x != nil {
+		var scanned scanXmp
+		if err := xmp.Unmarshal(&xmp.Meta{Xmp: x}, &scanned); err != nil {
+			reportOnce(err)
+		}
+		s.Make, s.Model = scanned.Make, scanned.Model
+		s.XmpDateTime = scanned.DateTimeOriginal
+	}
+	if tags, err := exif.ReadFile(scan); err == nil {
+		if s.Make == "" && s.Model == "" {
+			s.Make = tags.IFD0[exif.TagMake].Text
+			s.Model = tags.IFD0[exif.TagModel].Text
+		}
+		s.ExifDateTime = tags.Exif[exif.TagDateTimeOriginal].Text
+		_, s.exifDigitized = tags.Exif[exif.TagDateTimeDigitized]
+	}
+
+	if frame.Camera != nil && s.Make == frame.CameraMakeName() &&
+		s.Model == frame.Camera.Title {
+		s.Make = ""
+		s.Model = ""
+	}
+	if isFrameDate(s.XmpDateTime, frame) {
+		s.XmpDateTime = ""
+	}
+	if t, ok := frame.Time(); ok &&
+		s.ExifDateTime == t.Format(e4f.ExifTimeLayout) {
+		s.ExifDateTime = ""
+	}
+	return
+}
+
+// Layouts of the XMP dates without time zone.
+var xmpDateLayouts = []string{
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// Parse a XMP date, or an e4f timestamp. zoned is false without time
+// zone, the wall time being in UTC.
+func parseXmpDate(s string) (t time.Time, zoned bool, ok bool) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, true, true
+	}
+	for _, layout := range xmpDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, false, true
+		}
+	}
+	if t, err := e4f.ParseTime(s); err == nil {
+		return t, false, true
+	}
+	return t, false, false
+}
+
+// Whether the XMP date s is the one written for the frame, see
+// dateTimeOriginal: the same time, or the same wall time if either
+// has no time zone.
+func isFrameDate(s string, frame *e4f.Frame) bool {
+	t, zoned, ok := parseXmpDate(s)
+	if !ok {
+		return false
+	}
+	value, ok := dateTimeOriginal(frame)
+	if !ok {
+		return false
+	}
+	var written time.Time
+	writtenZoned := false
+	switch v := value.(type) {
+	case time.Time:
+		written, writtenZoned = v, true
+	case string:
+		if written, writtenZoned, ok = parseXmpDate(v); !ok {
+			return false
+		}
+	}
+	if zoned && writtenZoned {
+		return t.Equal(written)
+	}
+	const wall = "2006-01-02T15:04:05.999999999"
+	return t.Format(wall) == written.Format(wall)
+}
+
+func (s scannerInfo) hasIdentity() bool {
+	return s.Make != "" || s.Model != ""
+}
+
+func setIfMissing(x xmp.Xmp, ns xmp.Namespace, name string, value string) {
+	if _, found := xmp.GetProperty(x, ns, name); !found && value != "" {
+		xmp.SetProperty(x, ns, name, value, 0)
+	}
+}
+
+// Apply the policy to x, where the frame has been written.
+func (s scannerInfo) applyToXmp(x xmp.Xmp, policy scannerPolicy) {
+	switch policy {
+	case scannerKeep:
+		// Nothing of the camera is left with the scanner.
+		if s.hasIdentity() {
+			xmp.DeleteProperty(x, xmp.NS_TIFF, "Make")
+			xmp.DeleteProperty(x, xmp.NS_TIFF, "Model")
+		}
+		if s.XmpDateTime != "" {
+			xmp.DeleteProperty(x, xmp.NS_EXIF, "OffsetTimeOriginal")
+		}
+		reportOnce(xmp.MarshalInto(x, &scanXmp{
+			Make:             s.Make,
+			Model:            s.Model,
+			DateTimeOriginal: s.XmpDateTime,
+		}))
+	case scannerMove:
+		setIfMissing(x, xmp.NS_ANALOG, "ScannerMaker", s.Make)
+		setIfMissing(x, xmp.NS_ANALOG, "Scanner", s.Model)
+		setIfMissing(x, xmp.NS_EXIF, "DateTimeDigitized",
+			s.XmpDateTime)
+	}
+}
+
+// Apply the policy to the EXIF tags to write. EXIF has no field for
+// the scanner, so moving it only happens if it is recorded in the
+// XMP.
+func (s scannerInfo) applyToExif(tags *exif.Tags, policy scannerPolicy,
+	recorded bool) {
+
+	if policy == scannerOverwrite {
+		return
+	}
+	if s.hasIdentity() && (policy == scannerKeep || !recorded) {
+		delete(tags.IFD0, exif.TagMake)
+		delete(tags.IFD0, exif.TagModel)
+	}
+	if s.ExifDateTime == "" {
+		return
+	}
+	if policy == scannerKeep {
+		delete(tags.Exif, exif.TagDateTimeOriginal)
+		delete(tags.Exif, exif.TagOffsetTimeOriginal)
+	} else if !s.exifDigitized {
+		tags.Exif[exif.TagDateTimeDigitized] = exif.Ascii(s.ExifDateTime)
+	}
+}
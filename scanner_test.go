@@ -0,0 +1,119 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"gitlab.com/photo/e4f-go/src/e4f"
+	"gitlab.com/photo/e4f-go/src/xmp"
+)
+
+// Writing a frame twice into the XMP of its scan doesn't take the time
+// written the first time for the scan time.
+func TestScannerWriteTwice(t *testing.T) {
+	options.Mapping = defaultMapping()
+	db := e4f.Parse("samples/export-Roll-20130630_203650.xml")
+	roll := db.ExposedRolls[0]
+	exps := db.ExposuresForRoll(roll.Id)
+	// Frame 2 estimated, frame 3 with its time zone resolved.
+	exps[1].TimeTaken = ""
+	db.EstimateMissing(2)
+	options.Zones = map[int]*time.Location{
+		exps[2].Id: time.FixedZone("EDT", -4*3600),
+	}
+	defer func() { options.Zones = nil }()
+
+	for _, policy := range []scannerPolicy{scannerMove, scannerKeep} {
+		for i, exp := range exps[:3] {
+			frame := db.Frame(roll, exp, i)
+			x := xmp.NewEmpty()
+			for pass := 0; pass < 2; pass++ {
+				scanner := readScanner("", x, frame)
+				if scanner.XmpDateTime != "" {
+					t.Errorf("%s, frame %d, pass %d: scan time %q",
+						policy, frame.Number(), pass,
+						scanner.XmpDateTime)
+				}
+				updateScanXmp(x, frame, scanner, policy)
+			}
+			if d, found := xmp.GetProperty(x, xmp.NS_EXIF,
+				"DateTimeDigitized"); found {
+				t.Errorf("%s, frame %d: DateTimeDigitized %q",
+					policy, frame.Number(), d)
+			}
+			xmp.Free(x)
+		}
+	}
+
+	// The time of a scanner is still moved.
+	frame := db.Frame(roll, exps[0], 0)
+	x := xmp.NewEmpty()
+	defer xmp.Free(x)
+	xmp.SetProperty(x, xmp.NS_EXIF, "DateTimeOriginal",
+		"2020-01-02T03:04:05", 0)
+	updateScanXmp(x, frame, readScanner("", x, frame), scannerMove)
+	if d, _ := xmp.GetProperty(x, xmp.NS_EXIF, "DateTimeDigitized"); d != "2020-01-02T03:04:05" {
+		t.Errorf("DateTimeDigitized is %q", d)
+	}
+}
+
+func TestIsFrameDate(t *testing.T) {
+	db := e4f.Parse("samples/export-Roll-20130630_203650.xml")
+	roll := db.ExposedRolls[0]
+	exp := db.ExposuresForRoll(roll.Id)[0]
+	frame := db.Frame(roll, exp, 0)
+	// Frame 1 at 2013-06-30T17:51:53.
+	options.Zones = map[int]*time.Location{
+		exp.Id: time.FixedZone("EDT", -4*3600),
+	}
+	defer func() { options.Zones = nil }()
+
+	for s, expected := range map[string]bool{
+		"2013-06-30T17:51:53-04:00": true,
+		"2013-06-30T21:51:53Z":      true,
+		"2013-06-30T17:51:53":       true,
+		"2013-06-30T17:51:53+02:00": false,
+		"2013-06-30T17:51:54":       false,
+		"":                          false,
+	} {
+		if isFrameDate(s, frame) != expected {
+			t.Errorf("%q: expected %v", s, expected)
+		}
+	}
+}
+
+// Keeping the scanner leaves nothing of the camera with it.
+func TestScannerKeep(t *testing.T) {
+	options.Mapping = defaultMapping()
+	db := e4f.Parse("samples/export-Roll-20130630_203650.xml")
+	roll := db.ExposedRolls[0]
+	exp := db.ExposuresForRoll(roll.Id)[0]
+	frame := db.Frame(roll, exp, 0)
+	options.Zones = map[int]*time.Location{
+		exp.Id: time.FixedZone("EDT", -4*3600),
+	}
+	defer func() { options.Zones = nil }()
+
+	x := xmp.NewEmpty()
+	defer xmp.Free(x)
+	xmp.SetProperty(x, xmp.NS_TIFF, "Make", "Nikon", 0)
+	xmp.SetProperty(x, xmp.NS_EXIF, "DateTimeOriginal",
+		"2020-01-02T03:04:05", 0)
+	updateScanXmp(x, frame, readScanner("", x, frame), scannerKeep)
+
+	for _, test := range []struct {
+		ns       xmp.Namespace
+		name     string
+		expected string
+	}{
+		{xmp.NS_TIFF, "Make", "Nikon"},
+		{xmp.NS_TIFF, "Model", ""},
+		{xmp.NS_EXIF, "DateTimeOriginal", "2020-01-02T03:04:05"},
+		{xmp.NS_EXIF, "OffsetTimeOriginal", ""},
+	} {
+		if value, _ := xmp.GetProperty(x, test.ns, test.name); value != test.expected {
+			t.Errorf("%s is %q, expected %q", test.name, value,
+				test.expected)
+		}
+	}
+}
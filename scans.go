@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gitlab.com/photo/e4f-go/src/e4f"
+	"gitlab.com/photo/e4f-go/src/exif"
+	"gitlab.com/photo/e4f-go/src/xmp"
+)
+
+// File extensions recognized as scans.
+var scanExtensions = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".tif":  true,
+	".tiff": true,
+	".png":  true,
+	".dng":  true,
+}
+
+// Match the scans found in dir with the exposures of a roll.
+// Scans are sorted by file name and assigned in order, the first
+// scan being the first exposure. The result is parallel to exps,
+// with "" when there is no scan for the exposure.
+func matchScans(dir string, exps []*e4f.Exposure) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if scanExtensions[ext] {
+			files = append(files, entry.Name())
+		}
+	}
+	sort.Strings(files)
+
+	if len(files) != len(exps) {
+		log.Printf("%s: %d scans for %d exposures", dir, len(files),
+			len(exps))
+	}
+
+	scans := make([]string, len(exps))
+	for i := range exps {
+		if i < len(files) {
+			scans[i] = filepath.Join(dir, files[i])
+		}
+	}
+	return scans, nil
+}
+
+// Return the path of the XMP sidecar for scan, or "" if there is none.
+// Both "name.xmp" and "name.ext.xmp" are recognized.
+func sidecarPath(scan string) string {
+	candidates := []string{
+		strings.TrimSuffix(scan, filepath.Ext(scan)) + ".xmp",
+		scan + ".xmp",
+	}
+	for _, path := range candidates {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// Read the XMP for scan, from the sidecar if there is one, otherwise
+// from the file itself. Return nil if there is no XMP.
+func readScanXmp(scan string) xmp.Xmp {
+	if sidecar := sidecarPath(scan); sidecar != "" {
+		buffer, err := os.ReadFile(sidecar)
+		if err != nil {
+			log.Printf("Can't read %s: %s", sidecar, err)
+			return nil
+		}
+		return xmp.New(buffer)
+	}
+
+	xf := xmp.FilesOpenNew(scan, xmp.OPEN_READ|xmp.OPEN_ONLYXMP)
+	if xf == nil {
+		return nil
+	}
+	defer xmp.FilesFree(xf)
+	x := xmp.FilesGetNewXmp(xf)
+	xmp.FilesClose(xf, xmp.CLOSE_NOOPTION)
+	return x
+}
+
+// Write the XMP sidecar for scan.
+func writeSidecar(scan string, x xmp.Xmp) error {
+	path := sidecarPath(scan)
+	if path == "" {
+		path = strings.TrimSuffix(scan, filepath.Ext(scan)) + ".xmp"
+	}
+
+	buffer := xmp.StringNew()
+	defer xmp.StringFree(buffer)
+	if !xmp.Serialize(x, buffer, xmp.SERIAL_OMITPACKETWRAPPER, 0) {
+		return fmt.Errorf("can't serialize XMP: %d", xmp.GetError())
+	}
+	return os.WriteFile(path, []byte(xmp.StringGo(buffer)), 0644)
+}
+
+// Write the frame into the XMP x of its scan, replacing what was
+// written before, and apply the scanner policy.
+func updateScanXmp(x xmp.Xmp, frame *e4f.Frame, scanner scannerInfo,
+	policy scannerPolicy) {
+
+	for _, prop := range ownedProperties() {
+		xmp.DeleteProperty(x, prop.ns, prop.name)
+	}
+	options.Mapping.apply(x, frame)
+	scanner.applyToXmp(x, policy)
+}
+
+// Write the frames of roll into their scans, as XMP sidecars and/or
+// EXIF. The existing XMP is updated, with the scanner handled
+// according to policy.
+func writeScans(db *e4f.E4fDb, roll *e4f.ExposedRoll, scans []string,
+	writeXmp bool, writeExif bool, policy scannerPolicy) {
+
+	for i, exp := range db.ExposuresForRoll(roll.Id) {
+		scan := scans[i]
+		if scan == "" {
+			continue
+		}
+		frame := db.Frame(roll, exp, i)
+
+		x := readScanXmp(scan)
+		scanner := readScanner(scan, x, frame)
+		if writeXmp {
+			if x == nil {
+				x = xmp.NewEmpty()
+			}
+			updateScanXmp(x, frame, scanner, policy)
+			if err := writeSidecar(scan, x); err != nil {
+				log.Printf("Frame %d, %s: %s", frame.Number(),
+					scan, err)
+			}
+		}
+		if x != nil {
+			xmp.Free(x)
+		}
+
+		if writeExif {
+			if policy == scannerMove && scanner.hasIdentity() &&
+				!writeXmp {
+				log.Printf("Frame %d, %s: scanner kept in EXIF, "+
+					"use -write-xmp to move it",
+					frame.Number(), scan)
+			}
+			tags := frameToExif(frame)
+			scanner.applyToExif(tags, policy, writeXmp)
+			if err := exif.WriteFile(scan, tags); err != nil {
+				log.Printf("Frame %d, %s: %s", frame.Number(),
+					scan, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"gitlab.com/photo/e4f-go/src/e4f"
+	"gitlab.com/photo/e4f-go/src/xmp"
+)
+
+// A value of the frame for the mapping: a string, an int, a float64, a
+// bool, a time.Time or a []string, the items of an array. ok is false if there is none.
+type frameSource func(f *e4f.Frame) (value interface{}, ok bool)
+
+func nonEmpty(s string) (interface{}, bool) {
+	return s, s != ""
+}
+
+func nonZero(i int) (interface{}, bool) {
+	return i, i != 0
+}
+
+// Return an e4f timestamp of the roll in the time zone.
+func rollTime(s string) (interface{}, bool) {
+	if s == "" {
+		return nil, false
+	}
+	t, err := e4f.ParseTime(s)
+	if err != nil {
+		reportOnce(err)
+		return nil, false
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(),
+		t.Second(), 0, options.TimeZone), true
+}
+
+// Layout of a XMP date without time zone.
+const xmpLocalDateLayout = "2006-01-02T15:04:05"
+
+// The exif:DateTimeOriginal: with the offset if the time zone of the
+// frame is known, else without time zone.
+func dateTimeOriginal(f *e4f.Frame) (interface{}, bool) {
+	if loc, resolved := frameZone(f); resolved {
+		if t, ok := f.TimeIn(loc); ok {
+			return t, true
+		}
+		return nil, false
+	}
+	if t, ok := f.Time(); ok {
+		return t.Format(xmpLocalDateLayout), true
+	}
+	return nil, false
+}
+
+// The offset of DateTimeOriginal, like "-04:00", if the time zone of
+// the frame is known.
+func offsetTimeOriginal(f *e4f.Frame) (interface{}, bool) {
+	if loc, resolved := frameZone(f); resolved {
+		if t, ok := f.TimeIn(loc); ok {
+			return t.Format("-07:00"), true
+		}
+	}
+	return nil, false
+}
+
+func lensInfo(f *e4f.Frame) (interface{}, bool) {
+	lens := f.Lens
+	widest, narrowest, ok := f.LensApertures()
+	if !ok || lens.FocalLengthMin == 0 || lens.FocalLengthMax == 0 {
+		return nil, false
+	}
+	return fmt.Sprintf("%v %v %v %v",
+		xmp.FloatRational(lens.FocalLengthMin),
+		xmp.FloatRational(lens.FocalLengthMax),
+		xmp.FloatRational(widest), xmp.FloatRational(narrowest)), true
+}
+
+func placeField(field func(p *place) string) frameSource {
+	return func(f *e4f.Frame) (interface{}, bool) {
+		p := framePlace(f)
+		if p == nil {
+			return nil, false
+		}
+		return nonEmpty(field(p))
+	}
+}
+
+// The sources from -rights. Without it, their properties are left as
+// they are, see ownedProperties.
+var rightsSources = map[string]bool{
+	"copyright":         true,
+	"rightsMarked":      true,
+	"usageTerms":        true,
+	"webStatement":      true,
+	"license":           true,
+	"contactAddress":    true,
+	"contactCity":       true,
+	"contactRegion":     true,
+	"contactPostalCode": true,
+	"contactCountry":    true,
+	"contactPhone":      true,
+	"contactEmail":      true,
+	"contactUrl":        true,
+}
+
+// The values of the frame the mapping can use, by name.
+var frameSources map[string]frameSource
+
+func init() {
+	frameSources = map[string]frameSource{
+		"number": func(f *e4f.Frame) (interface{}, bool) {
+			return f.Number(), true
+		},
+		"description": func(f *e4f.Frame) (interface{}, bool) {
+			return nonEmpty(f.Exposure.Desc)
+		},
+		"artist": func(f *e4f.Frame) (interface{}, bool) {
+			names := f.ArtistNames()
+			return names, len(names) > 0
+		},
+		"dateTimeOriginal":   dateTimeOriginal,
+		"offsetTimeOriginal": offsetTimeOriginal,
+		"timeEstimated": func(f *e4f.Frame) (interface{}, bool) {
+			return true, f.TimeEstimated
+		},
+		"iso": func(f *e4f.Frame) (interface{}, bool) {
+			return nonZero(f.Roll.Iso)
+		},
+		"sensitivityType": func(f *e4f.Frame) (interface{}, bool) {
+			if _, ok := f.PushPull(); !ok {
+				return nil, false
+			}
+			return f.SensitivityType(), true
+		},
+		"recommendedExposureIndex": func(f *e4f.Frame) (interface{}, bool) {
+			if f.SensitivityType() !=
+				e4f.SensitivityRecommendedExposureIndex {
+				return nil, false
+			}
+			return f.Roll.Iso, true
+		},
+		"pushPull": func(f *e4f.Frame) (interface{}, bool) {
+			stops, ok := f.PushPull()
+			if !ok {
+				return nil, false
+			}
+			return e4f.FormatStops(stops), true
+		},
+		"shutterSpeed": func(f *e4f.Frame) (interface{}, bool) {
+			return nonEmpty(f.Exposure.ShutterSpeed)
+		},
+		"aperture": func(f *e4f.Frame) (interface{}, bool) {
+			return f.Aperture()
+		},
+		"focalLength": func(f *e4f.Frame) (interface{}, bool) {
+			focal := f.Exposure.FocalLength
+			return focal, focal != 0
+		},
+		"camera": func(f *e4f.Frame) (interface{}, bool) {
+			return nonEmpty(f.CameraDescription())
+		},
+		"cameraMake": func(f *e4f.Frame) (interface{}, bool) {
+			return nonEmpty(f.CameraMakeName())
+		},
+		"cameraModel": func(f *e4f.Frame) (interface{}, bool) {
+			if f.Camera == nil {
+				return nil, false
+			}
+			return nonEmpty(f.Camera.Title)
+		},
+		"cameraSerialNumber": func(f *e4f.Frame) (interface{}, bool) {
+			if f.Camera == nil {
+				return nil, false
+			}
+			return nonEmpty(f.Camera.SerialNumber)
+		},
+		"cameraDefaultFilmType": func(f *e4f.Frame) (interface{}, bool) {
+			if f.Camera == nil {
+				return nil, false
+			}
+			return nonEmpty(e4f.NormalizeFilmType(f.Camera.DefaultFilmType))
+		},
+		"cameraDefaultFrameCount": func(f *e4f.Frame) (interface{}, bool) {
+			if f.Camera == nil {
+				return nil, false
+			}
+			return nonZero(f.Camera.DefaultFrameCount)
+		},
+		"lens": func(f *e4f.Frame) (interface{}, bool) {
+			return nonEmpty(f.LensDescription())
+		},
+		"lensWidestAperture": func(f *e4f.Frame) (interface{}, bool) {
+			if f.Lens == nil {
+				return nil, false
+			}
+			widest, _, _ := f.LensApertures()
+			return widest, widest != 0
+		},
+		"lensInfo": lensInfo,
+		"lensSerialNumber": func(f *e4f.Frame) (interface{}, bool) {
+			if f.Lens == nil {
+				return nil, false
+			}
+			return nonEmpty(f.Lens.SerialNumber)
+		},
+		"rollId": func(f *e4f.Frame) (interface{}, bool) {
+			return f.Roll.Id, true
+		},
+		"rollDescription": func(f *e4f.Frame) (interface{}, bool) {
+			return nonEmpty(f.Roll.Desc)
+		},
+		"rollFrameCount": func(f *e4f.Frame) (interface{}, bool) {
+			return nonZero(f.Roll.FrameCount)
+		},
+		"rollLoaded": func(f *e4f.Frame) (interface{}, bool) {
+			return rollTime(f.Roll.TimeLoaded)
+		},
+		"rollUnloaded": func(f *e4f.Frame) (interface{}, bool) {
+			return rollTime(f.Roll.TimeUnloaded)
+		},
+		"filmMaker": func(f *e4f.Frame) (interface{}, bool) {
+			return nonEmpty(f.FilmMakeName())
+		},
+		"film": func(f *e4f.Frame) (interface{}, bool) {
+			return nonEmpty(f.FilmLabel())
+		},
+		"filmType": func(f *e4f.Frame) (interface{}, bool) {
+			return nonEmpty(f.FilmType())
+		},
+		"filmProcess": func(f *e4f.Frame) (interface{}, bool) {
+			if f.Film == nil {
+				return nil, false
+			}
+			return nonEmpty(f.Film.Process)
+		},
+		"filmColorType": func(f *e4f.Frame) (interface{}, bool) {
+			if f.Film == nil {
+				return nil, false
+			}
+			return nonEmpty(f.Film.ColorType)
+		},
+		"filmSpeed": func(f *e4f.Frame) (interface{}, bool) {
+			if f.Film == nil {
+				return nil, false
+			}
+			return nonZero(f.Film.Iso)
+		},
+		"flashFired": func(f *e4f.Frame) (interface{}, bool) {
+			return f.Flash().Fired, true
+		},
+		"flashReturn": func(f *e4f.Frame) (interface{}, bool) {
+			return f.Flash().Return, true
+		},
+		"flashMode": func(f *e4f.Frame) (interface{}, bool) {
+			return f.Flash().Mode, true
+		},
+		"flashFunction": func(f *e4f.Frame) (interface{}, bool) {
+			return f.Flash().Function, true
+		},
+		"flashRedEyeMode": func(f *e4f.Frame) (interface{}, bool) {
+			return f.Flash().RedEyeMode, true
+		},
+		"meteringMode": func(f *e4f.Frame) (interface{}, bool) {
+			meteringMode, _ := frameEnums(f)
+			return meteringMode, true
+		},
+		"lightSource": func(f *e4f.Frame) (interface{}, bool) {
+			_, lightSource := frameEnums(f)
+			return lightSource, true
+		},
+		"gpsVersionID": func(f *e4f.Frame) (interface{}, bool) {
+			v := e4f.GpsVersionID
+			return fmt.Sprintf("%d.%d.%d.%d", v[0], v[1], v[2], v[3]),
+				f.Gps != nil
+		},
+		"gpsMapDatum": func(f *e4f.Frame) (interface{}, bool) {
+			return e4f.GpsMapDatum, f.Gps != nil
+		},
+		"gpsAltitude": func(f *e4f.Frame) (interface{}, bool) {
+			if f.Gps == nil {
+				return nil, false
+			}
+			tenths, _ := e4f.GpsAltitude(f.Gps.Alt)
+			return float64(tenths) / 10, true
+		},
+		"gpsAltitudeRef": func(f *e4f.Frame) (interface{}, bool) {
+			if f.Gps == nil {
+				return nil, false
+			}
+			_, ref := e4f.GpsAltitude(f.Gps.Alt)
+			return ref, true
+		},
+		"gpsLatitude": func(f *e4f.Frame) (interface{}, bool) {
+			if f.Gps == nil {
+				return nil, false
+			}
+			return e4f.FormatGpsCoord(f.Gps.Lat, 'N',
+				options.GpsPrecision), true
+		},
+		"gpsLongitude": func(f *e4f.Frame) (interface{}, bool) {
+			if f.Gps == nil {
+				return nil, false
+			}
+			return e4f.FormatGpsCoord(f.Gps.Long, 'E',
+				options.GpsPrecision), true
+		},
+		"gpsTimeStamp": func(f *e4f.Frame) (interface{}, bool) {
+			if f.Gps == nil {
+				return nil, false
+			}
+			if t, ok := frameUTCTime(f); ok {
+				return t, true
+			}
+			return nil, false
+		},
+		"locationEstimated": func(f *e4f.Frame) (interface{}, bool) {
+			return true, f.GpsEstimated
+		},
+		"city":        placeField(func(p *place) string { return p.City }),
+		"state":       placeField(func(p *place) string { return p.State }),
+		"country":     placeField(func(p *place) string { return p.Country }),
+		"countryCode": placeField(func(p *place) string { return p.CountryCode }),
+		"location":    placeField(func(p *place) string { return p.Location }),
+
+		"copyright": rightsField(func(r *rights) string { return r.Copyright }),
+		"rightsMarked": func(f *e4f.Frame) (interface{}, bool) {
+			r := frameRights(f)
+			if r == nil || r.Marked == nil {
+				return nil, false
+			}
+			return *r.Marked, true
+		},
+		"usageTerms":     rightsField(func(r *rights) string { return r.UsageTerms }),
+		"webStatement":   rightsField(func(r *rights) string { return r.WebStatement }),
+		"license":        rightsField(func(r *rights) string { return r.License }),
+		"contactAddress": contactField(func(c *contactInfo) string { return c.Address }),
+		"contactCity":    contactField(func(c *contactInfo) string { return c.City }),
+		"contactRegion":  contactField(func(c *contactInfo) string { return c.Region }),
+		"contactPostalCode": contactField(func(c *contactInfo) string {
+			return c.PostalCode
+		}),
+		"contactCountry": contactField(func(c *contactInfo) string { return c.Country }),
+		"contactPhone":   contactField(func(c *contactInfo) string { return c.Phone }),
+		"contactEmail":   contactField(func(c *contactInfo) string { return c.Email }),
+		"contactUrl":     contactField(func(c *contactInfo) string { return c.Url }),
+	}
+}
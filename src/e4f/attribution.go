@@ -0,0 +1,178 @@
+package e4f
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Who shot the frames a rule selects. The conditions that are set
+// must all match.
+type AttributionRule struct {
+	// The artist names, the first being the main one.
+	Artists []string `json:"artists"`
+
+	// The roll id.
+	Roll int `json:"roll,omitempty"`
+	// Frame numbers of the roll, 1 based. Empty for all.
+	Frames []int `json:"frames,omitempty"`
+	// The camera, its title or its description, ignoring the case.
+	Camera string `json:"camera,omitempty"`
+	// Regular expression matching the roll description.
+	RollDescription string `json:"rollDescription,omitempty"`
+	// The time the frame was taken, "2006-01-02" or
+	// "2006-01-02T15:04:05", both included.
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+
+	rollDescription *regexp.Regexp
+	from, to        time.Time
+}
+
+// The rules to attribute the frames. A later rule overrides the
+// earlier ones, so per-frame rules follow the per-roll ones.
+type Attribution struct {
+	Rules []AttributionRule `json:"rules"`
+}
+
+// Load the attribution rules from a JSON file.
+func LoadAttribution(path string) (*Attribution, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	attribution := &Attribution{}
+	if err = json.Unmarshal(data, attribution); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return attribution, nil
+}
+
+// Parse a rule bound. to is the end of the day for a date.
+func parseBound(s string, to bool) (time.Time, error) {
+	if t, err := time.Parse(timeLayout, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return t, fmt.Errorf("invalid date %q", s)
+	}
+	if to {
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return t, nil
+}
+
+// Check and parse the rule.
+func (r *AttributionRule) compile() (err error) {
+	if len(r.Artists) == 0 {
+		return fmt.Errorf("rule without artists")
+	}
+	if r.RollDescription != "" {
+		r.rollDescription, err = regexp.Compile(r.RollDescription)
+		if err != nil {
+			return err
+		}
+	}
+	if r.From != "" {
+		if r.from, err = parseBound(r.From, false); err != nil {
+			return err
+		}
+	}
+	if r.To != "" {
+		if r.to, err = parseBound(r.To, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *AttributionRule) matches(f *Frame) bool {
+	if r.Roll != 0 && f.Roll.Id != r.Roll {
+		return false
+	}
+	if len(r.Frames) > 0 {
+		found := false
+		for _, n := range r.Frames {
+			found = found || n == f.Number()
+		}
+		if !found {
+			return false
+		}
+	}
+	if r.Camera != "" {
+		if f.Camera == nil ||
+			!strings.EqualFold(r.Camera, f.Camera.Title) &&
+				!strings.EqualFold(r.Camera, f.CameraDescription()) {
+			return false
+		}
+	}
+	if r.rollDescription != nil &&
+		!r.rollDescription.MatchString(f.Roll.Desc) {
+		return false
+	}
+	if r.From != "" || r.To != "" {
+		t, ok := f.Time()
+		if !ok || r.From != "" && t.Before(r.from) ||
+			r.To != "" && t.After(r.to) {
+			return false
+		}
+	}
+	return true
+}
+
+// Return the artist named name, adding it if needed.
+func (db *E4fDb) artist(name string) *Artist {
+	for _, artist := range db.Artists {
+		if strings.TrimSpace(artist.Name) == name {
+			return artist
+		}
+	}
+	artist := &Artist{Name: name}
+	db.Artists = append(db.Artists, artist)
+	return artist
+}
+
+// Attribute the frames the rules select. The others keep the default
+// artist, if any.
+func (db *E4fDb) ApplyAttribution(a *Attribution) error {
+	for i := range a.Rules {
+		if err := a.Rules[i].compile(); err != nil {
+			return fmt.Errorf("rule %d: %w", i+1, err)
+		}
+	}
+	if db.FrameArtists == nil {
+		db.FrameArtists = make(map[int][]*Artist)
+	}
+	for _, roll := range db.ExposedRolls {
+		for _, frame := range db.FramesForRoll(roll) {
+			for i := len(a.Rules) - 1; i >= 0; i-- {
+				rule := &a.Rules[i]
+				if !rule.matches(frame) {
+					continue
+				}
+				var artists []*Artist
+				for _, name := range rule.Artists {
+					artists = append(artists,
+						db.artist(strings.TrimSpace(name)))
+				}
+				db.FrameArtists[frame.Exposure.Id] = artists
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// Return the names of the artists of the frame.
+func (f *Frame) ArtistNames() (names []string) {
+	for _, artist := range f.Artists {
+		if name := strings.TrimSpace(artist.Name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return
+}
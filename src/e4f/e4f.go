@@ -23,6 +23,9 @@ type E4fDb struct {
 	Films        []*Film
 	Lenses       []*Lens
 	Artists      []*Artist
+	// The artist of the frames without FrameArtists, the first of the
+	// export. nil without any.
+	DefaultArtist *Artist
 
 	RollMap   map[int]*ExposedRoll
 	MakeMap   map[int]*Make
@@ -30,6 +33,12 @@ type E4fDb struct {
 	GpsMap    map[int]*GpsLocation
 	LensMap   map[int]*Lens
 	FilmMap   map[int]*Film
+
+	// Estimated values, per exposure id. See EstimateMissing.
+	Estimates map[int]*Estimate
+	// The artists, per exposure id, when not the default one. See
+	// ApplyAttribution.
+	FrameArtists map[int][]*Artist
 }
 
 // Build the id -> data maps for the various elements
@@ -113,7 +122,7 @@ type Exposure struct {
 	GpsLocId     int
 	ExpComp      int
 	RollId       int
-	FocalLength  int
+	FocalLength  float64
 	LightSource  string
 	TimeTaken    string
 	ShutterSpeed string
@@ -138,8 +147,8 @@ type Lens struct {
 	MakeId         int
 	ApertureMin    string
 	ApertureMax    string
-	FocalLengthMin int
-	FocalLengthMax int
+	FocalLengthMin float64
+	FocalLengthMax float64
 }
 
 type Artist struct {
@@ -266,7 +275,7 @@ func Parse(file string) *E4fDb {
 				decoder.OnTextOf("exposure_roll_id",
 					toInt(&exp.RollId))
 				decoder.OnTextOf("exposure_focal_length",
-					toInt(&exp.FocalLength))
+					toFloat(&exp.FocalLength))
 				decoder.OnTextOf("exposure_light_source",
 					exml.Assign(&exp.LightSource))
 				decoder.OnTextOf("exposure_time_taken",
@@ -311,9 +320,9 @@ func Parse(file string) *E4fDb {
 				decoder.OnTextOf("lens_aperture_max",
 					exml.Assign(&lens.ApertureMax))
 				decoder.OnTextOf("lens_focal_length_min",
-					toInt(&lens.FocalLengthMin))
+					toFloat(&lens.FocalLengthMin))
 				decoder.OnTextOf("lens_focal_length_max",
-					toInt(&lens.FocalLengthMax))
+					toFloat(&lens.FocalLengthMax))
 			})
 		decoder.On("Artist/dk.codeunited.exif4film.model.Artist",
 			func(attrs exml.Attrs) {
@@ -325,6 +334,9 @@ func Parse(file string) *E4fDb {
 	})
 	decoder.Run()
 
+	if len(e4fDb.Artists) > 0 {
+		e4fDb.DefaultArtist = e4fDb.Artists[0]
+	}
 	e4fDb.buildMaps()
 
 	return e4fDb
@@ -332,25 +344,18 @@ func Parse(file string) *E4fDb {
 
 func (db *E4fDb) Print(roll *ExposedRoll) {
 	fmt.Printf("%s\n", roll.Desc)
-	var filmLabel string
+	var label string
 	film, found := db.FilmMap[roll.FilmId]
 	if film != nil && found {
-		mk := db.MakeMap[film.MakeId]
-		var filmMake string
-		if mk != nil && mk.Name != "" {
-			filmMake = mk.Name
-		}
-		if film.Title != "" {
-			if filmMake != "" {
-				filmLabel = fmt.Sprintf("%s %s", mk.Name,
-					film.Title)
-			} else {
-				filmLabel = film.Title
-			}
-		}
+		label = filmLabel(film, db.MakeMap[film.MakeId])
 	}
 
-	fmt.Printf("Type %s, %s, %d ISO\n", roll.FilmType, filmLabel, roll.Iso)
+	fmt.Printf("Type %s, %s, %d ISO\n", roll.FilmType, label, roll.Iso)
+	if film != nil && found {
+		if pushPull := pushPullLabel(label, film.Iso, roll.Iso); pushPull != "" {
+			fmt.Printf("%s\n", pushPull)
+		}
+	}
 
 	camera, found := db.CameraMap[roll.CameraId]
 	if camera != nil && found {
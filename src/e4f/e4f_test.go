@@ -1,6 +1,14 @@
 package e4f
 
-import "testing"
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestE4f(t *testing.T) {
 	e4fDb := Parse("../../samples/export-Roll-20130630_203650.xml")
@@ -22,3 +30,787 @@ func TestE4f(t *testing.T) {
 		t.Errorf("Found %d exposures", l)
 	}
 }
+
+func TestParseFocalLength(t *testing.T) {
+	data, err := os.ReadFile("../../samples/export-Roll-20130630_203650.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := strings.Replace(string(data),
+		"<exposure_focal_length>50<", "<exposure_focal_length>10.5<", 1)
+	s = strings.Replace(s,
+		"<lens_focal_length_min>50<", "<lens_focal_length_min>4.5<", 1)
+	file := filepath.Join(t.TempDir(), "export.xml")
+	if err := os.WriteFile(file, []byte(s), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	e4fDb := Parse(file)
+	found := false
+	for _, exp := range e4fDb.Exposures {
+		found = found || exp.FocalLength == 10.5
+	}
+	if !found {
+		t.Error("No exposure at 10.5mm")
+	}
+	found = false
+	for _, lens := range e4fDb.Lenses {
+		found = found || lens.FocalLengthMin == 4.5
+	}
+	if !found {
+		t.Error("No lens from 4.5mm")
+	}
+}
+
+func TestExifEnums(t *testing.T) {
+	meteringModes := map[string]int{
+		"":                MeteringUnknown,
+		"Average":         MeteringAverage,
+		"Center Weighted": MeteringCenterWeightedAverage,
+		"SPOT":            MeteringSpot,
+		"Matrix":          MeteringPattern,
+		"Partial":         MeteringPartial,
+	}
+	for s, expected := range meteringModes {
+		if v, err := MeteringModeValue(s); err != nil || v != expected {
+			t.Errorf("MeteringMode %q is %d (%v), expected %d", s, v,
+				err, expected)
+		}
+	}
+	lightSources := map[string]int{
+		"Daylight":     LightDaylight,
+		"Tungsten":     LightTungsten,
+		"fluorescent":  LightFluorescent,
+		"Cloudy":       LightCloudyWeather,
+		"Shade":        LightShade,
+		"Flash":        LightFlash,
+		"Incandescent": LightTungsten,
+	}
+	for s, expected := range lightSources {
+		if v, err := LightSourceValue(s); err != nil || v != expected {
+			t.Errorf("LightSource %q is %d (%v), expected %d", s, v,
+				err, expected)
+		}
+	}
+
+	if v, err := LightSourceValue("Moonlight"); err == nil || v != 0 {
+		t.Errorf("Unknown LightSource is %d (%v)", v, err)
+	}
+
+	if v := (Flash{Fired: true}).Value(); v != 0x01 {
+		t.Errorf("Flash fired is %#x", v)
+	}
+	flash := Flash{Fired: true, Return: FlashReturnDetected,
+		Mode: FlashModeAuto, RedEyeMode: true}
+	if v := flash.Value(); v != 0x5f {
+		t.Errorf("Flash is %#x, expected 0x5f", v)
+	}
+}
+
+func TestGpsCoord(t *testing.T) {
+	coords := []struct {
+		lat, long   float64
+		latS, longS string
+	}{
+		// Montreal
+		{45.49763382, -73.63209495, "45,29.858029N", "73,37.925697W"},
+		// Sydney
+		{-33.8567844, 151.213108, "33,51.407064S", "151,12.786480E"},
+		// Buenos Aires
+		{-34.6036844, -58.3815591, "34,36.221064S", "58,22.893546W"},
+		// Tokyo
+		{35.6761919, 139.6503106, "35,40.571514N", "139,39.018636E"},
+	}
+	for _, c := range coords {
+		lat := FormatGpsCoord(c.lat, 'N', DefaultGpsPrecision)
+		long := FormatGpsCoord(c.long, 'E', DefaultGpsPrecision)
+		if lat != c.latS || long != c.longS {
+			t.Errorf("%f,%f formatted as %s %s, expected %s %s",
+				c.lat, c.long, lat, long, c.latS, c.longS)
+		}
+
+		parsedLat, err := ParseGpsCoord(lat)
+		if err != nil || math.Abs(parsedLat-c.lat) > 1e-8 {
+			t.Errorf("%s parsed as %f (%v), expected %f", lat,
+				parsedLat, err, c.lat)
+		}
+		parsedLong, err := ParseGpsCoord(long)
+		if err != nil || math.Abs(parsedLong-c.long) > 1e-8 {
+			t.Errorf("%s parsed as %f (%v), expected %f", long,
+				parsedLong, err, c.long)
+		}
+	}
+
+	if s := FormatGpsCoord(-33.8567844, 'N', 2); s != "33,51.41S" {
+		t.Errorf("Precision 2 is %s", s)
+	}
+	if s := FormatGpsCoord(10.99999999, 'E', 2); s != "11,0.00E" {
+		t.Errorf("Rounding to 60 minutes gives %s", s)
+	}
+	if f, err := ParseGpsCoord("45,29,51.48N"); err != nil ||
+		math.Abs(f-45.4976333) > 1e-6 {
+		t.Errorf("DMS parsed as %f (%v)", f, err)
+	}
+
+	if alt, ref := GpsAltitude(-12.34); alt != 123 || ref != 1 {
+		t.Errorf("Altitude -12.34 is %d ref %d", alt, ref)
+	}
+	if alt, ref := GpsAltitude(110.9000015258789); alt != 1109 || ref != 0 {
+		t.Errorf("Altitude 110.9 is %d ref %d", alt, ref)
+	}
+}
+
+func TestGpsTimeStamp(t *testing.T) {
+	e4fDb := Parse("../../samples/export-Roll-20130630_203650.xml")
+	roll := e4fDb.ExposedRolls[0]
+	frame := e4fDb.FramesForRoll(roll)[0]
+
+	loc, err := time.LoadLocation("America/Montreal")
+	if err != nil {
+		t.Skip(err)
+	}
+	utc, ok := frame.UTCTime(loc)
+	if !ok {
+		t.Fatal("No time for the frame")
+	}
+	if s := utc.Format(time.RFC3339); s != "2013-06-30T21:51:53Z" {
+		t.Errorf("UTC time is %s", s)
+	}
+}
+
+func TestPrivacy(t *testing.T) {
+	e4fDb := Parse("../../samples/export-Roll-20130630_203650.xml")
+	e4fDb.ExposedRolls[0].Desc = "At home"
+
+	// Frame 1 at 45.49763382,-73.63209495, frame 2 about 100m away.
+	privacy := &Privacy{
+		Zones: []PrivacyZone{
+			{Name: "home", Lat: 45.4976, Long: -73.6321, Radius: 50},
+		},
+		FuzzGrid: 0.01,
+	}
+	if err := privacy.SetStrip("serials,descriptions"); err != nil {
+		t.Fatal(err)
+	}
+	if err := privacy.SetStrip("faces"); err == nil {
+		t.Error("Unknown strip item accepted")
+	}
+	e4fDb.ApplyPrivacy(privacy)
+
+	frames := e4fDb.FramesForRoll(e4fDb.ExposedRolls[0])
+	if frames[0].Gps != nil {
+		t.Errorf("Location in zone kept: %v", frames[0].Gps)
+	}
+	gps := frames[1].Gps
+	if gps == nil {
+		t.Fatal("Location outside zone dropped")
+	}
+	if math.Abs(gps.Lat-45.50) > 1e-9 || math.Abs(gps.Long+73.63) > 1e-9 {
+		t.Errorf("Location not fuzzed: %v", gps)
+	}
+	if frames[1].Exposure.Desc != "" {
+		t.Error("Description not stripped")
+	}
+	if frames[1].Roll.Desc != "" {
+		t.Error("Roll description not stripped")
+	}
+	if frames[1].Lens.SerialNumber != "" {
+		t.Error("Lens serial number not stripped")
+	}
+
+	privacy = &Privacy{}
+	privacy.SetStrip("gps")
+	e4fDb.ApplyPrivacy(privacy)
+	if l := len(e4fDb.GpsLocations); l != 0 {
+		t.Errorf("%d locations left", l)
+	}
+}
+
+func TestGeotag(t *testing.T) {
+	e4fDb := Parse("../../samples/export-Roll-20130630_203650.xml")
+	e4fDb.buildMaps()
+
+	roll := e4fDb.ExposedRolls[0]
+	frames := e4fDb.FramesForRoll(roll)
+	// Frame 1 is at 2013-06-30T17:51:53, frame 2 at 17:54:08.
+	frames[0].Exposure.GpsLocId = 0
+	frames[1].Exposure.GpsLocId = 0
+	count := len(e4fDb.GpsLocations)
+
+	at := func(s string) time.Time {
+		t, _ := time.Parse(time.RFC3339, s)
+		return t
+	}
+	// The logger is one minute ahead.
+	track := []TrackPoint{
+		{at("2013-06-30T17:56:53Z"), 46, -74, 100},
+		{at("2013-06-30T17:52:53Z"), 45, -73, 0},
+	}
+	results := e4fDb.Geotag(track, GeotagOptions{
+		Location: time.UTC,
+		Offset:   time.Minute,
+		MaxGap:   5 * time.Minute,
+	})
+	if l := len(results); l != 2 {
+		t.Fatalf("Located %d frames, expected 2", l)
+	}
+	if l := len(e4fDb.GpsLocations); l != count+2 {
+		t.Errorf("%d locations, expected %d", l, count+2)
+	}
+
+	frames = e4fDb.FramesForRoll(roll)
+	if gps := frames[0].Gps; gps == nil || gps.Lat != 45 || gps.Long != -73 {
+		t.Errorf("Frame 1 located at %v", gps)
+	}
+	// 135s out of 240s.
+	if gps := frames[1].Gps; gps == nil ||
+		math.Abs(gps.Lat-45.5625) > 1e-9 || math.Abs(gps.Alt-56.25) > 1e-9 {
+		t.Errorf("Frame 2 located at %v", gps)
+	}
+	if results[1].After == nil {
+		t.Error("Frame 2 not interpolated")
+	}
+
+	// Too far from the track.
+	frames[2].Exposure.GpsLocId = 0
+	results = e4fDb.Geotag(track, GeotagOptions{
+		Location: time.UTC,
+		MaxGap:   30 * time.Second,
+	})
+	if l := len(results); l != 0 {
+		t.Errorf("Located %d frames, expected none", l)
+	}
+
+	// The zone of the roll is used instead of Location.
+	results = e4fDb.Geotag(track, GeotagOptions{
+		Location:      time.FixedZone("UTC+5", 5*3600),
+		RollLocations: map[int]*time.Location{roll.Id: time.UTC},
+		MaxGap:        5 * time.Minute,
+	})
+	if l := len(results); l != 1 || results[0].Frame != 3 {
+		t.Errorf("Located %v, expected frame 3", results)
+	}
+}
+
+func TestEstimateMissing(t *testing.T) {
+	e4fDb := Parse("../../samples/export-Roll-20130630_203650.xml")
+	e4fDb.buildMaps()
+
+	roll := e4fDb.ExposedRolls[0]
+	frames := e4fDb.FramesForRoll(roll)
+	// Frames 1 to 3 at 17:51:53, 17:54:08 and 17:55:58.
+	exp := frames[1].Exposure
+	exp.TimeTaken = ""
+	exp.GpsLocId = 0
+	a, c := frames[0].Gps, frames[2].Gps
+
+	e4fDb.EstimateMissing(1)
+	if len(e4fDb.Estimates) != 0 {
+		t.Error("Estimated beyond the gap")
+	}
+
+	e4fDb.EstimateMissing(2)
+	if exp.TimeTaken != "" || exp.GpsLocId != 0 {
+		t.Error("Exposure changed")
+	}
+	frame := e4fDb.FramesForRoll(roll)[1]
+	if !frame.TimeEstimated || !frame.GpsEstimated {
+		t.Fatal("Frame 2 not estimated")
+	}
+	tm, _ := frame.Time()
+	if s := tm.Format(timeLayout); s != "2013-06-30T17:53:56" {
+		t.Errorf("Estimated time %s", s)
+	}
+	if math.Abs(frame.Gps.Lat-(a.Lat+c.Lat)/2) > 1e-9 ||
+		math.Abs(frame.Gps.Long-(a.Long+c.Long)/2) > 1e-9 {
+		t.Errorf("Estimated location %v", frame.Gps)
+	}
+	if frames[0].TimeEstimated || frames[0].GpsEstimated {
+		t.Error("Frame 1 estimated")
+	}
+}
+
+func TestTimeShift(t *testing.T) {
+	loc, err := time.LoadLocation("America/Montreal")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	// 01:30 EDT plus one hour is 01:30 EST.
+	s, err := shiftTime("2013-11-03T01:30:00Z307", time.Hour, loc)
+	if err != nil || s != "2013-11-03T01:30:00Z307" {
+		t.Errorf("Shifted to %s, %v", s, err)
+	}
+	s, _ = shiftTime("2013-06-30T23:30:00Z181", time.Hour, loc)
+	if s != "2013-07-01T00:30:00Z182" {
+		t.Errorf("Shifted to %s", s)
+	}
+
+	e4fDb := Parse("../../samples/export-Roll-20130630_203650.xml")
+	e4fDb.buildMaps()
+	roll := e4fDb.ExposedRolls[0]
+	exps := e4fDb.ExposuresForRoll(roll.Id)
+	// Frame 1 at 17:51:53, frame 2 at 17:54:08.
+	err = e4fDb.ApplyTimeShift(&TimeShift{
+		Roll:   roll.Id,
+		Frames: []int{2},
+		Frame:  1,
+		Time:   "2013-06-30T18:51:53",
+	}, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exps[0].TimeTaken != "2013-06-30T17:51:53Z181" {
+		t.Errorf("Frame 1 shifted to %s", exps[0].TimeTaken)
+	}
+	if exps[1].TimeTaken != "2013-06-30T18:54:08Z181" {
+		t.Errorf("Frame 2 shifted to %s", exps[1].TimeTaken)
+	}
+
+	if err = e4fDb.ApplyTimeShift(&TimeShift{Roll: roll.Id}, loc); err == nil {
+		t.Error("Shift without offset accepted")
+	}
+
+	// The frames are selected by exposure number, not by order.
+	exps[0].Number, exps[1].Number = 2, 1
+	err = e4fDb.ApplyTimeShift(&TimeShift{
+		Roll: roll.Id, Frames: []int{2}, Offset: "-1h",
+	}, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exps[0].TimeTaken != "2013-06-30T16:51:53Z181" {
+		t.Errorf("Exposure 2 shifted to %s", exps[0].TimeTaken)
+	}
+
+	// Nothing is shifted on error.
+	loaded := roll.TimeLoaded
+	if loaded == "" {
+		t.Fatal("No time loaded")
+	}
+	err = e4fDb.ApplyTimeShift(&TimeShift{
+		Roll: roll.Id, Frames: []int{1, 99}, Offset: "1h",
+	}, loc)
+	if err == nil {
+		t.Error("Unknown frame accepted")
+	}
+	if exps[1].TimeTaken != "2013-06-30T18:54:08Z181" {
+		t.Errorf("Shifted on error to %s", exps[1].TimeTaken)
+	}
+	exps[2].TimeTaken = "yesterday"
+	err = e4fDb.ApplyTimeShift(&TimeShift{Roll: roll.Id, Offset: "1h"}, loc)
+	if err == nil {
+		t.Error("Invalid time accepted")
+	}
+	if roll.TimeLoaded != loaded {
+		t.Errorf("Roll loaded time shifted on error to %s", roll.TimeLoaded)
+	}
+}
+
+func TestLint(t *testing.T) {
+	e4fDb := Parse("../../samples/export-Roll-20130630_203650.xml")
+	e4fDb.buildMaps()
+
+	roll := e4fDb.ExposedRolls[0]
+	clean := make(map[string]bool)
+	for _, finding := range e4fDb.LintRoll(roll) {
+		clean[finding.Check] = true
+	}
+
+	exps := e4fDb.ExposuresForRoll(roll.Id)
+	// The 50mm f1.8 lens.
+	exps[0].FocalLength = 35
+	exps[1].Aperture = "1.4"
+	exps[2].TimeTaken = "2013-06-30T17:50:00Z181"
+	exps[3].Number = exps[4].Number
+	roll.Iso = 1600
+
+	expected := map[string]Severity{
+		"focal-length":     SeverityError,
+		"aperture":         SeverityError,
+		"time-order":       SeverityWarning,
+		"duplicate-number": SeverityError,
+		"iso":              SeverityInfo,
+	}
+	for _, finding := range e4fDb.LintRoll(roll) {
+		if clean[finding.Check] {
+			continue
+		}
+		severity, found := expected[finding.Check]
+		if !found {
+			t.Errorf("Unexpected finding %s", finding)
+			continue
+		}
+		if severity != finding.Severity {
+			t.Errorf("Finding %s, expected %s", finding, severity)
+		}
+		delete(expected, finding.Check)
+	}
+	for check := range expected {
+		t.Errorf("Missing %s finding", check)
+	}
+}
+
+func TestLintTimeOrder(t *testing.T) {
+	e4fDb := Parse("../../samples/export-Roll-20130630_203650.xml")
+	// The exposures in reverse order in the export.
+	exps := e4fDb.Exposures
+	for i, j := 0, len(exps)-1; i < j; i, j = i+1, j-1 {
+		exps[i], exps[j] = exps[j], exps[i]
+	}
+	e4fDb.buildMaps()
+
+	roll := e4fDb.ExposedRolls[0]
+	for _, exp := range e4fDb.ExposuresForRoll(roll.Id) {
+		if exp.Number == 3 {
+			exp.TimeTaken = "2013-06-30T17:50:00Z181"
+		}
+	}
+	var found []Finding
+	for _, finding := range e4fDb.LintRoll(roll) {
+		if finding.Check == "time-order" {
+			found = append(found, finding)
+		}
+	}
+	if len(found) != 1 || !strings.Contains(found[0].Message, "exposure 2") {
+		t.Errorf("Found %v, expected exposure 3 before 2", found)
+	}
+}
+
+func TestCameraTimelines(t *testing.T) {
+	e4fDb := &E4fDb{
+		Cameras: []*Camera{{Id: 1}},
+		ExposedRolls: []*ExposedRoll{
+			{Id: 1, CameraId: 1, TimeLoaded: "2013-06-01T10:00:00Z152",
+				TimeUnloaded: "2013-06-10T10:00:00Z161"},
+			{Id: 2, CameraId: 1, TimeLoaded: "2013-06-05T10:00:00Z156",
+				TimeUnloaded: "2013-06-20T10:00:00Z171"},
+			// Times from the frames.
+			{Id: 3, CameraId: 1},
+		},
+		Exposures: []*Exposure{
+			{Id: 1, RollId: 1, Number: 1, TimeTaken: "2013-06-02T10:00:00Z153"},
+			{Id: 2, RollId: 1, Number: 2, TimeTaken: "2013-06-06T10:00:00Z157"},
+			{Id: 3, RollId: 2, Number: 1, TimeTaken: "2013-06-08T10:00:00Z159"},
+			{Id: 4, RollId: 2, Number: 2, TimeTaken: "2013-06-09T10:00:00Z160"},
+			{Id: 5, RollId: 2, Number: 3, TimeTaken: "2013-06-15T10:00:00Z166"},
+			{Id: 6, RollId: 3, Number: 1, TimeTaken: "2013-07-01T10:00:00Z182"},
+		},
+	}
+	e4fDb.buildMaps()
+
+	timelines := e4fDb.CameraTimelines()
+	if len(timelines) != 1 || len(timelines[0].Rolls) != 3 {
+		t.Fatalf("Found %v", timelines)
+	}
+	timeline := timelines[0]
+	if len(timeline.Overlaps) != 1 {
+		t.Fatalf("Found %d overlaps, expected 1", len(timeline.Overlaps))
+	}
+	disputed := timeline.Overlaps[0].Disputed
+	if len(disputed) != 3 {
+		t.Fatalf("Found %d disputed frames, expected 3", len(disputed))
+	}
+	// Roll 1 frame 2 is closer to roll 2 frame 1 than to roll 1
+	// frame 1.
+	if d := disputed[0]; d.Roll.Id != 1 || d.Frame != 2 || d.Owner.Id != 2 {
+		t.Errorf("Disputed roll %d frame %d owned by %d", d.Roll.Id,
+			d.Frame, d.Owner.Id)
+	}
+	if d := disputed[1]; d.Roll.Id != 2 || d.Owner.Id != 2 {
+		t.Errorf("Disputed roll %d frame %d owned by %d", d.Roll.Id,
+			d.Frame, d.Owner.Id)
+	}
+
+	if len(timeline.Gaps) != 1 || timeline.Gaps[0].After.Roll.Id != 3 {
+		t.Errorf("Found gaps %v", timeline.Gaps)
+	}
+}
+
+// A roll loaded without unload or frame times stays in the camera
+// until the next one is loaded.
+func TestCameraTimelinesLoadedOnly(t *testing.T) {
+	e4fDb := &E4fDb{
+		Cameras: []*Camera{{Id: 1}},
+		ExposedRolls: []*ExposedRoll{
+			{Id: 1, CameraId: 1, TimeLoaded: "2013-06-01T10:00:00Z152",
+				TimeUnloaded: "2013-06-10T10:00:00Z161"},
+			{Id: 2, CameraId: 1, TimeLoaded: "2013-06-12T10:00:00Z163"},
+			{Id: 3, CameraId: 1, TimeLoaded: "2013-06-20T10:00:00Z171",
+				TimeUnloaded: "2013-06-25T10:00:00Z176"},
+			{Id: 4, CameraId: 1, TimeLoaded: "2013-06-24T10:00:00Z175"},
+		},
+	}
+	e4fDb.buildMaps()
+
+	timelines := e4fDb.CameraTimelines()
+	if len(timelines) != 1 || len(timelines[0].Rolls) != 4 {
+		t.Fatalf("Found %v", timelines)
+	}
+	timeline := timelines[0]
+	rolls := timeline.Rolls
+	if !rolls[1].Unloaded.Equal(rolls[2].Loaded) {
+		t.Errorf("Roll 2 unloaded at %v", rolls[1].Unloaded)
+	}
+	if !rolls[3].Unloaded.IsZero() {
+		t.Errorf("Roll 4 unloaded at %v", rolls[3].Unloaded)
+	}
+
+	if len(timeline.Overlaps) != 1 {
+		t.Fatalf("Found %d overlaps, expected 1", len(timeline.Overlaps))
+	}
+	overlap := timeline.Overlaps[0]
+	if overlap.First.Roll.Id != 3 || overlap.Second.Roll.Id != 4 ||
+		!overlap.End.Equal(rolls[2].Unloaded) ||
+		overlap.End.Before(overlap.Start) {
+		t.Errorf("Overlap of rolls %d and %d from %v to %v",
+			overlap.First.Roll.Id, overlap.Second.Roll.Id,
+			overlap.Start, overlap.End)
+	}
+
+	if len(timeline.Gaps) != 1 || timeline.Gaps[0].Before.Roll.Id != 1 ||
+		timeline.Gaps[0].After.Roll.Id != 2 {
+		t.Errorf("Found gaps %v", timeline.Gaps)
+	}
+}
+
+func TestPushPull(t *testing.T) {
+	tests := []struct {
+		filmIso, rollIso int
+		stops            string
+		label            string
+	}{
+		{400, 1600, "+2", "Tri-X 400 pushed +2 to 1600"},
+		{400, 200, "-1", "Tri-X 400 pulled -1 to 200"},
+		{400, 500, "+1/3", "Tri-X 400 pushed +1/3 to 500"},
+		{100, 320, "+1 2/3", "Tri-X 400 pushed +1 2/3 to 320"},
+		{400, 400, "+0", ""},
+	}
+	for _, test := range tests {
+		stops, ok := PushPull(test.filmIso, test.rollIso)
+		if s := FormatStops(stops); !ok || s != test.stops {
+			t.Errorf("%d at %d: %s, expected %s", test.filmIso,
+				test.rollIso, s, test.stops)
+		}
+		label := pushPullLabel("Tri-X 400", test.filmIso, test.rollIso)
+		if label != test.label {
+			t.Errorf("Label %q, expected %q", label, test.label)
+		}
+	}
+	if _, ok := PushPull(0, 400); ok {
+		t.Error("Unknown film speed accepted")
+	}
+}
+
+func TestRebuild(t *testing.T) {
+	db := Parse("../../samples/export-Roll-20130630_203650.xml")
+	roll := db.ExposedRolls[0]
+
+	var records []*FrameRecord
+	frames := db.FramesForRoll(roll)
+	// Read in reverse, the numbers put them back in order.
+	for i := len(frames) - 1; i >= 0; i-- {
+		f := frames[i]
+		records = append(records, &FrameRecord{
+			RollKey:      roll.Desc,
+			Number:       f.Number(),
+			Desc:         f.Exposure.Desc,
+			TimeTaken:    f.Exposure.TimeTaken,
+			Aperture:     f.Exposure.Aperture,
+			CameraMake:   f.CameraMakeName(),
+			CameraModel:  f.Camera.Title,
+			Lens:         f.LensDescription(),
+			RollDesc:     roll.Desc,
+			Iso:          roll.Iso,
+			FilmType:     f.FilmType(),
+			FilmMake:     f.FilmMakeName(),
+			Film:         f.FilmLabel(),
+			FilmIso:      f.Film.Iso,
+			MeteringMode: MeteringModeName(MeteringSpot),
+			Gps:          f.Gps,
+		})
+	}
+	// Only the last frame, read first, has a creator.
+	records[0].Artists = []string{"Jane Doe"}
+	records = append(records, &FrameRecord{RollKey: "other", Number: 1})
+
+	rebuilt := Rebuild(records)
+	if l := len(rebuilt.ExposedRolls); l != 2 {
+		t.Fatalf("Found %d rolls, expected 2", l)
+	}
+	if l := len(rebuilt.Cameras); l != 1 {
+		t.Errorf("Found %d cameras, expected 1", l)
+	}
+	if l := len(rebuilt.Lenses); l != 2 {
+		t.Errorf("Found %d lenses, expected 2", l)
+	}
+	if l := len(rebuilt.Films); l != 1 {
+		t.Errorf("Found %d films, expected 1", l)
+	}
+	if l := len(rebuilt.Makes); l != 2 {
+		t.Errorf("Found %d makes, expected 2", l)
+	}
+	rebuiltRoll := rebuilt.ExposedRolls[0]
+	if rebuiltRoll.Desc != roll.Desc || rebuiltRoll.Iso != roll.Iso ||
+		rebuiltRoll.FilmType != roll.FilmType {
+		t.Errorf("Roll %+v, expected %+v", rebuiltRoll, roll)
+	}
+
+	rebuiltFrames := rebuilt.FramesForRoll(rebuiltRoll)
+	if len(rebuiltFrames) != len(frames) {
+		t.Fatalf("Found %d frames, expected %d", len(rebuiltFrames),
+			len(frames))
+	}
+	for i, f := range rebuiltFrames {
+		expected := frames[i]
+		if f.Number() != expected.Number() ||
+			f.Exposure.TimeTaken != expected.Exposure.TimeTaken ||
+			f.CameraDescription() != expected.CameraDescription() ||
+			f.LensDescription() != expected.LensDescription() ||
+			f.FilmLabel() != expected.FilmLabel() {
+			t.Errorf("Frame %d: %+v, expected %+v", i+1, f.Exposure,
+				expected.Exposure)
+		}
+		if (f.Gps == nil) != (expected.Gps == nil) ||
+			f.Gps != nil && (f.Gps.Lat != expected.Gps.Lat ||
+				f.Gps.Long != expected.Gps.Long) {
+			t.Errorf("Frame %d: GPS %v, expected %v", i+1, f.Gps,
+				expected.Gps)
+		}
+		if mode, _ := f.MeteringMode(); mode != MeteringSpot {
+			t.Errorf("Frame %d: metering mode %d, expected %d", i+1,
+				mode, MeteringSpot)
+		}
+		names := f.ArtistNames()
+		if i == len(frames)-1 {
+			if len(names) != 1 || names[0] != "Jane Doe" {
+				t.Errorf("Frame %d: artists %v, expected [Jane Doe]",
+					i+1, names)
+			}
+		} else if len(names) != 0 {
+			t.Errorf("Frame %d: artists %v, expected none", i+1, names)
+		}
+	}
+}
+
+func TestExifEnumNames(t *testing.T) {
+	for value, name := range meteringModeNames {
+		if v, err := MeteringModeValue(name); err != nil || v != value {
+			t.Errorf("MeteringModeValue(%q) = %d, %v, expected %d", name,
+				v, err, value)
+		}
+	}
+	for value, name := range lightSourceNames {
+		if v, err := LightSourceValue(name); err != nil || v != value {
+			t.Errorf("LightSourceValue(%q) = %d, %v, expected %d", name,
+				v, err, value)
+		}
+	}
+}
+
+func TestAttribution(t *testing.T) {
+	db := Parse("../../samples/export-Roll-20130630_203650.xml")
+	roll := db.ExposedRolls[0]
+	attribution := &Attribution{Rules: []AttributionRule{
+		{Artists: []string{"Jane Doe"}, Camera: "canon ae1 program"},
+		{Artists: []string{"John Roe", "Jane Doe"}, Roll: roll.Id,
+			Frames: []int{2, 3}},
+		{Artists: []string{"Nobody"}, RollDescription: "^nomatch$"},
+		{Artists: []string{"Late"}, From: "2013-07-01"},
+	}}
+	if err := db.ApplyAttribution(attribution); err != nil {
+		t.Fatal(err)
+	}
+	frames := db.FramesForRoll(roll)
+	expected := map[int][]string{
+		1: {"Jane Doe"},
+		2: {"John Roe", "Jane Doe"},
+		3: {"John Roe", "Jane Doe"},
+		4: {"Jane Doe"},
+	}
+	for number, names := range expected {
+		found := frames[number-1].ArtistNames()
+		if strings.Join(found, ",") != strings.Join(names, ",") {
+			t.Errorf("Frame %d: artists %v, expected %v", number, found,
+				names)
+		}
+	}
+	// The export artist, then the two added.
+	if l := len(db.Artists); l != 3 {
+		t.Errorf("Found %d artists, expected 3", l)
+	}
+
+	for _, rule := range []AttributionRule{
+		{},
+		{Artists: []string{"Jane Doe"}, From: "July"},
+		{Artists: []string{"Jane Doe"}, RollDescription: "("},
+	} {
+		bad := &Attribution{Rules: []AttributionRule{rule}}
+		if err := db.ApplyAttribution(bad); err == nil {
+			t.Errorf("Rule %+v should fail", rule)
+		}
+	}
+}
+
+// Without an artist in the export, the frames no rule selects have
+// none.
+func TestAttributionNoExportArtist(t *testing.T) {
+	data, err := os.ReadFile("../../samples/export-Roll-20130630_203650.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := regexp.MustCompile("<Artist>.*</Artist>").ReplaceAllString(
+		string(data), "")
+	file := filepath.Join(t.TempDir(), "export.xml")
+	if err := os.WriteFile(file, []byte(s), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db := Parse(file)
+	roll := db.ExposedRolls[0]
+	attribution := &Attribution{Rules: []AttributionRule{
+		{Artists: []string{"Jane Doe"}, Roll: roll.Id, Frames: []int{2}},
+	}}
+	if err := db.ApplyAttribution(attribution); err != nil {
+		t.Fatal(err)
+	}
+	frames := db.FramesForRoll(roll)
+	if names := frames[0].ArtistNames(); len(names) != 0 {
+		t.Errorf("Frame 1: artists %v, expected none", names)
+	}
+	if names := frames[1].ArtistNames(); len(names) != 1 ||
+		names[0] != "Jane Doe" {
+		t.Errorf("Frame 2: artists %v, expected [Jane Doe]", names)
+	}
+}
+
+func TestAttributionDates(t *testing.T) {
+	db := Parse("../../samples/export-Roll-20130630_203650.xml")
+	roll := db.ExposedRolls[0]
+	frames := db.FramesForRoll(roll)
+	first, _ := frames[0].Time()
+	attribution := &Attribution{Rules: []AttributionRule{
+		{Artists: []string{"Day"}, From: first.Format("2006-01-02"),
+			To: first.Format("2006-01-02")},
+		{Artists: []string{"First"},
+			To: first.Format("2006-01-02T15:04:05")},
+	}}
+	if err := db.ApplyAttribution(attribution); err != nil {
+		t.Fatal(err)
+	}
+	frames = db.FramesForRoll(roll)
+	if names := frames[0].ArtistNames(); len(names) != 1 ||
+		names[0] != "First" {
+		t.Errorf("Frame 1: artists %v, expected [First]", names)
+	}
+	for _, f := range frames[1:] {
+		ft, ok := f.Time()
+		if !ok || ft.YearDay() != first.YearDay() || ft.Equal(first) {
+			continue
+		}
+		if names := f.ArtistNames(); len(names) != 1 || names[0] != "Day" {
+			t.Errorf("Frame %d: artists %v, expected [Day]", f.Number(),
+				names)
+		}
+	}
+}
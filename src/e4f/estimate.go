@@ -0,0 +1,103 @@
+package e4f
+
+import (
+	"sort"
+	"time"
+)
+
+// Values of an exposure estimated from the frames around it.
+type Estimate struct {
+	// Zero if not estimated.
+	Time time.Time
+	// nil if not estimated. The id is the opposite of the exposure
+	// id, and it isn't in GpsLocations.
+	Gps *GpsLocation
+}
+
+// Estimate the missing times and locations of the exposures by
+// interpolating between the frames before and after, in the order of
+// the exposure numbers. The frames used can be at most maxGap numbers
+// apart. The exposures are left untouched: the estimates are used when
+// resolving the frames.
+func (db *E4fDb) EstimateMissing(maxGap int) {
+	db.Estimates = make(map[int]*Estimate)
+	for _, roll := range db.ExposedRolls {
+		exps := db.ExposuresForRoll(roll.Id)
+		sort.SliceStable(exps, func(i, j int) bool {
+			return exps[i].Number < exps[j].Number
+		})
+
+		times := make([]*time.Time, len(exps))
+		locations := make([]*GpsLocation, len(exps))
+		for i, exp := range exps {
+			if t, err := ParseTime(exp.TimeTaken); exp.TimeTaken != "" &&
+				err == nil {
+				times[i] = &t
+			}
+			locations[i] = db.GpsMap[exp.GpsLocId]
+		}
+
+		for i, exp := range exps {
+			estimate := &Estimate{}
+			if times[i] == nil {
+				before, after, ratio := neighbours(exps, i, maxGap,
+					func(j int) bool { return times[j] != nil })
+				if ratio >= 0 {
+					d := times[after].Sub(*times[before])
+					estimate.Time = times[before].Add(
+						time.Duration(float64(d) * ratio)).
+						Round(time.Second)
+				}
+			}
+			if locations[i] == nil {
+				before, after, ratio := neighbours(exps, i, maxGap,
+					func(j int) bool { return locations[j] != nil })
+				if ratio >= 0 {
+					a, b := locations[before], locations[after]
+					interpolate := func(x, y float64) float64 {
+						return x + (y-x)*ratio
+					}
+					estimate.Gps = &GpsLocation{
+						Id:   -exp.Id,
+						Lat:  interpolate(a.Lat, b.Lat),
+						Long: interpolate(a.Long, b.Long),
+						Alt:  interpolate(a.Alt, b.Alt),
+					}
+				}
+			}
+			if !estimate.Time.IsZero() || estimate.Gps != nil {
+				db.Estimates[exp.Id] = estimate
+			}
+		}
+	}
+}
+
+// Find the known exposures before and after i, and the position of i
+// between them, from 0 to 1. ratio is -1 if there are none or they are
+// too far apart.
+func neighbours(exps []*Exposure, i int, maxGap int,
+	known func(int) bool) (before int, after int, ratio float64) {
+
+	before, after = -1, -1
+	for j := i - 1; j >= 0; j-- {
+		if known(j) {
+			before = j
+			break
+		}
+	}
+	for j := i + 1; j < len(exps); j++ {
+		if known(j) {
+			after = j
+			break
+		}
+	}
+	if before < 0 || after < 0 {
+		return before, after, -1
+	}
+	gap := exps[after].Number - exps[before].Number
+	if gap <= 0 || gap > maxGap {
+		return before, after, -1
+	}
+	return before, after,
+		float64(exps[i].Number-exps[before].Number) / float64(gap)
+}
@@ -0,0 +1,236 @@
+package e4f
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Exif 2.3 MeteringMode values
+const (
+	MeteringUnknown               = 0
+	MeteringAverage               = 1
+	MeteringCenterWeightedAverage = 2
+	MeteringSpot                  = 3
+	MeteringMultiSpot             = 4
+	MeteringPattern               = 5
+	MeteringPartial               = 6
+	MeteringOther                 = 255
+)
+
+// Exif 2.3 LightSource values
+const (
+	LightUnknown              = 0
+	LightDaylight             = 1
+	LightFluorescent          = 2
+	LightTungsten             = 3
+	LightFlash                = 4
+	LightFineWeather          = 9
+	LightCloudyWeather        = 10
+	LightShade                = 11
+	LightDaylightFluorescent  = 12
+	LightDayWhiteFluorescent  = 13
+	LightCoolWhiteFluorescent = 14
+	LightWhiteFluorescent     = 15
+	LightWarmWhiteFluorescent = 16
+	LightStandardA            = 17
+	LightStandardB            = 18
+	LightStandardC            = 19
+	LightD55                  = 20
+	LightD65                  = 21
+	LightD75                  = 22
+	LightD50                  = 23
+	LightISOStudioTungsten    = 24
+	LightOther                = 255
+)
+
+// The e4f values, normalized with normalizeEnum, and their aliases.
+var meteringModes = map[string]int{
+	"unknown":               MeteringUnknown,
+	"average":               MeteringAverage,
+	"centerweighted":        MeteringCenterWeightedAverage,
+	"centerweightedaverage": MeteringCenterWeightedAverage,
+	"centreweighted":        MeteringCenterWeightedAverage,
+	"spot":                  MeteringSpot,
+	"multispot":             MeteringMultiSpot,
+	"pattern":               MeteringPattern,
+	"matrix":                MeteringPattern,
+	"evaluative":            MeteringPattern,
+	"multisegment":          MeteringPattern,
+	"partial":               MeteringPartial,
+	"other":                 MeteringOther,
+}
+
+var lightSources = map[string]int{
+	"unknown":              LightUnknown,
+	"auto":                 LightUnknown,
+	"daylight":             LightDaylight,
+	"sunny":                LightDaylight,
+	"fluorescent":          LightFluorescent,
+	"tungsten":             LightTungsten,
+	"incandescent":         LightTungsten,
+	"flash":                LightFlash,
+	"fineweather":          LightFineWeather,
+	"cloudy":               LightCloudyWeather,
+	"cloudyweather":        LightCloudyWeather,
+	"overcast":             LightCloudyWeather,
+	"shade":                LightShade,
+	"daylightfluorescent":  LightDaylightFluorescent,
+	"daywhitefluorescent":  LightDayWhiteFluorescent,
+	"coolwhitefluorescent": LightCoolWhiteFluorescent,
+	"whitefluorescent":     LightWhiteFluorescent,
+	"warmwhitefluorescent": LightWarmWhiteFluorescent,
+	"standardlighta":       LightStandardA,
+	"standardlightb":       LightStandardB,
+	"standardlightc":       LightStandardC,
+	"d55":                  LightD55,
+	"d65":                  LightD65,
+	"d75":                  LightD75,
+	"d50":                  LightD50,
+	"isostudiotungsten":    LightISOStudioTungsten,
+	"studiotungsten":       LightISOStudioTungsten,
+	"other":                LightOther,
+	"otherlightsource":     LightOther,
+}
+
+// Error for a value that has no Exif equivalent.
+type UnknownValueError struct {
+	Field string
+	Value string
+}
+
+func (e *UnknownValueError) Error() string {
+	return fmt.Sprintf("unknown %s %q", e.Field, e.Value)
+}
+
+// Lower case, without spaces, dashes and underscores.
+func normalizeEnum(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '_':
+			return -1
+		}
+		return r
+	}, strings.ToLower(s))
+}
+
+func enumValue(field string, values map[string]int, s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	value, found := values[normalizeEnum(s)]
+	if !found {
+		return 0, &UnknownValueError{field, s}
+	}
+	return value, nil
+}
+
+// Return the Exif MeteringMode for the e4f value.
+// Unrecognized values are MeteringUnknown with an error.
+func MeteringModeValue(s string) (int, error) {
+	return enumValue("metering mode", meteringModes, s)
+}
+
+// Return the Exif LightSource for the e4f value.
+// Unrecognized values are LightUnknown with an error.
+func LightSourceValue(s string) (int, error) {
+	return enumValue("light source", lightSources, s)
+}
+
+// Exif Flash Return values
+const (
+	FlashReturnNone        = 0
+	FlashReturnNotDetected = 2
+	FlashReturnDetected    = 3
+)
+
+// Exif Flash Mode values
+const (
+	FlashModeUnknown     = 0
+	FlashModeFiring      = 1
+	FlashModeSuppression = 2
+	FlashModeAuto        = 3
+)
+
+// The Exif Flash, as a struct like in XMP.
+type Flash struct {
+	Fired      bool
+	Return     int
+	Mode       int
+	Function   bool
+	RedEyeMode bool
+}
+
+// The Exif Flash tag value.
+func (f Flash) Value() int {
+	value := f.Return<<1 | f.Mode<<3
+	if f.Fired {
+		value |= 0x01
+	}
+	if f.Function {
+		value |= 0x20
+	}
+	if f.RedEyeMode {
+		value |= 0x40
+	}
+	return value
+}
+
+// The flash of the frame. e4f only knows whether it fired.
+func (f *Frame) Flash() Flash {
+	return Flash{Fired: f.Exposure.FlashOn}
+}
+
+// The Exif MeteringMode of the frame.
+func (f *Frame) MeteringMode() (int, error) {
+	return MeteringModeValue(f.Exposure.MeteringMode)
+}
+
+// The Exif LightSource of the frame.
+func (f *Frame) LightSource() (int, error) {
+	return LightSourceValue(f.Exposure.LightSource)
+}
+
+// The e4f value for each Exif MeteringMode.
+var meteringModeNames = map[int]string{
+	MeteringAverage:               "Average",
+	MeteringCenterWeightedAverage: "Center Weighted Average",
+	MeteringSpot:                  "Spot",
+	MeteringMultiSpot:             "Multi Spot",
+	MeteringPattern:               "Pattern",
+	MeteringPartial:               "Partial",
+	MeteringOther:                 "Other",
+}
+
+var lightSourceNames = map[int]string{
+	LightDaylight:             "Daylight",
+	LightFluorescent:          "Fluorescent",
+	LightTungsten:             "Tungsten",
+	LightFlash:                "Flash",
+	LightFineWeather:          "Fine Weather",
+	LightCloudyWeather:        "Cloudy",
+	LightShade:                "Shade",
+	LightDaylightFluorescent:  "Daylight Fluorescent",
+	LightDayWhiteFluorescent:  "Day White Fluorescent",
+	LightCoolWhiteFluorescent: "Cool White Fluorescent",
+	LightWhiteFluorescent:     "White Fluorescent",
+	LightWarmWhiteFluorescent: "Warm White Fluorescent",
+	LightStandardA:            "Standard Light A",
+	LightStandardB:            "Standard Light B",
+	LightStandardC:            "Standard Light C",
+	LightD55:                  "D55",
+	LightD65:                  "D65",
+	LightD75:                  "D75",
+	LightD50:                  "D50",
+	LightISOStudioTungsten:    "ISO Studio Tungsten",
+	LightOther:                "Other",
+}
+
+// Return the e4f value for the Exif MeteringMode, "" if unknown.
+func MeteringModeName(value int) string {
+	return meteringModeNames[value]
+}
+
+// Return the e4f value for the Exif LightSource, "" if unknown.
+func LightSourceName(value int) string {
+	return lightSourceNames[value]
+}
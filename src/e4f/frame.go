@@ -0,0 +1,217 @@
+package e4f
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Frame is an exposure with all the entities it references
+// resolved. Any of the pointers may be nil if not found.
+type Frame struct {
+	// Index of the exposure in the roll, 0 based.
+	Index      int
+	Exposure   *Exposure
+	Roll       *ExposedRoll
+	Camera     *Camera
+	CameraMake *Make
+	Lens       *Lens
+	LensMake   *Make
+	Film       *Film
+	FilmMake   *Make
+	Gps        *GpsLocation
+	// The main artist first.
+	Artists []*Artist
+
+	// Whether the time or location are estimated.
+	TimeEstimated bool
+	GpsEstimated  bool
+	estimatedTime time.Time
+}
+
+// Resolve the frame for the exposure at index in roll.
+func (db *E4fDb) Frame(roll *ExposedRoll, exp *Exposure, index int) *Frame {
+	frame := &Frame{
+		Index:    index,
+		Exposure: exp,
+		Roll:     roll,
+	}
+
+	if camera, found := db.CameraMap[roll.CameraId]; found {
+		frame.Camera = camera
+		frame.CameraMake = db.MakeMap[camera.MakeId]
+	}
+	if lens, found := db.LensMap[exp.LensId]; found {
+		frame.Lens = lens
+		frame.LensMake = db.MakeMap[lens.MakeId]
+	}
+	if film, found := db.FilmMap[roll.FilmId]; found {
+		frame.Film = film
+		frame.FilmMake = db.MakeMap[film.MakeId]
+	}
+	if gps, found := db.GpsMap[exp.GpsLocId]; found {
+		frame.Gps = gps
+	}
+	if estimate, found := db.Estimates[exp.Id]; found {
+		if frame.Gps == nil && estimate.Gps != nil {
+			frame.Gps = estimate.Gps
+			frame.GpsEstimated = true
+		}
+		if exp.TimeTaken == "" && !estimate.Time.IsZero() {
+			frame.estimatedTime = estimate.Time
+			frame.TimeEstimated = true
+		}
+	}
+	if artists, found := db.FrameArtists[exp.Id]; found {
+		frame.Artists = artists
+	} else if db.DefaultArtist != nil {
+		frame.Artists = []*Artist{db.DefaultArtist}
+	}
+	return frame
+}
+
+// Resolve all the frames of roll.
+func (db *E4fDb) FramesForRoll(roll *ExposedRoll) (frames []*Frame) {
+	for i, exp := range db.ExposuresForRoll(roll.Id) {
+		frames = append(frames, db.Frame(roll, exp, i))
+	}
+	return
+}
+
+// The frame number, 1 based.
+func (f *Frame) Number() int {
+	return f.Index + 1
+}
+
+// Return the aperture as a number. ok is false if it can't be parsed.
+func (f *Frame) Aperture() (aperture float64, ok bool) {
+	return parseAperture(f.Exposure.Aperture)
+}
+
+// Return the shutter speed as a fraction of seconds. ok is false if
+// it can't be parsed.
+func (f *Frame) ExposureTime() (num int, den int, ok bool) {
+	return ParseShutterSpeed(f.Exposure.ShutterSpeed)
+}
+
+// Parse a shutter speed like "1/250", "2" or "2\"" into a fraction
+// of seconds.
+func ParseShutterSpeed(s string) (num int, den int, ok bool) {
+	s = strings.TrimSuffix(strings.TrimSpace(s), "\"")
+	n, d, isFraction := strings.Cut(s, "/")
+	if !isFraction {
+		d = "1"
+	}
+	num, err := strconv.Atoi(n)
+	if err != nil {
+		return 0, 0, false
+	}
+	den, err = strconv.Atoi(d)
+	if err != nil || den <= 0 || num <= 0 {
+		return 0, 0, false
+	}
+	return num, den, true
+}
+
+func parseAperture(s string) (float64, bool) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+	return f, true
+}
+
+// Return the camera maker name, or "".
+func (f *Frame) CameraMakeName() string {
+	if f.CameraMake == nil {
+		return ""
+	}
+	return f.CameraMake.Name
+}
+
+// Return the camera description, prefixed by the maker name
+// unless the title already has it.
+func (f *Frame) CameraDescription() string {
+	if f.Camera == nil {
+		return ""
+	}
+	maker := f.CameraMakeName()
+	if maker == "" || strings.HasPrefix(f.Camera.Title, maker) {
+		return f.Camera.Title
+	}
+	return fmt.Sprintf("%s %s", maker, f.Camera.Title)
+}
+
+// Return the lens description, prefixed by the maker name
+// unless the title already has it.
+func (f *Frame) LensDescription() string {
+	if f.Lens == nil {
+		return ""
+	}
+	var maker string
+	if f.LensMake != nil {
+		maker = f.LensMake.Name
+	}
+	if maker == "" || strings.HasPrefix(f.Lens.Title, maker) {
+		return f.Lens.Title
+	}
+	return fmt.Sprintf("%s %s", maker, f.Lens.Title)
+}
+
+// Return the lens widest and narrowest aperture.
+// In Exif the widest aperture is the lowest number, ApertureMin in e4f.
+func (f *Frame) LensApertures() (widest float64, narrowest float64,
+	ok bool) {
+	if f.Lens == nil {
+		return 0, 0, false
+	}
+	widest, ok = parseAperture(f.Lens.ApertureMin)
+	if !ok {
+		return
+	}
+	narrowest, ok = parseAperture(f.Lens.ApertureMax)
+	return
+}
+
+// Return the film maker name, or "".
+func (f *Frame) FilmMakeName() string {
+	if f.FilmMake == nil {
+		return ""
+	}
+	return f.FilmMake.Name
+}
+
+// Return the film label, maker and title.
+func (f *Frame) FilmLabel() string {
+	return filmLabel(f.Film, f.FilmMake)
+}
+
+func filmLabel(film *Film, mk *Make) string {
+	if film == nil || film.Title == "" {
+		return ""
+	}
+	if mk != nil && mk.Name != "" {
+		return fmt.Sprintf("%s %s", mk.Name, film.Title)
+	}
+	return film.Title
+}
+
+// Return the film type of the roll, normalized: "135", "120"...
+func (f *Frame) FilmType() string {
+	return NormalizeFilmType(f.Roll.FilmType)
+}
+
+// Normalize the e4f film type, ie strip the leading 'F' of the format
+// names.
+func NormalizeFilmType(filmType string) string {
+	switch filmType {
+	case "F120":
+		return "120"
+	case "F220":
+		return "220"
+	case "F135":
+		return "135"
+	}
+	return filmType
+}
@@ -0,0 +1,140 @@
+package e4f
+
+import (
+	"sort"
+	"time"
+)
+
+// A point of a GPS track.
+type TrackPoint struct {
+	Time           time.Time
+	Lat, Long, Alt float64
+}
+
+// How to match the frames to the track.
+type GeotagOptions struct {
+	// Time zone of the Exif4Film timestamps.
+	Location *time.Location
+	// Time zone of the timestamps of some rolls, by id, instead of
+	// Location.
+	RollLocations map[int]*time.Location
+	// Added to the frame time to get the track time, to correct the
+	// phone clock.
+	Offset time.Duration
+	// Maximum time between the frame and a track point, or between
+	// two points to interpolate.
+	MaxGap time.Duration
+}
+
+// A frame located from the track.
+type GeotagResult struct {
+	Roll  *ExposedRoll
+	Frame int
+	// The track time of the frame.
+	Time time.Time
+	Gps  *GpsLocation
+	// The points used. After is nil unless interpolated.
+	Before, After *TrackPoint
+}
+
+// Locate t on the track sorted by time. Return the location and the
+// points used, or nil.
+func locateOnTrack(track []TrackPoint, t time.Time,
+	maxGap time.Duration) (*GpsLocation, *TrackPoint, *TrackPoint) {
+
+	// First point after t.
+	i := sort.Search(len(track), func(i int) bool {
+		return track[i].Time.After(t)
+	})
+	var before, after *TrackPoint
+	if i > 0 {
+		before = &track[i-1]
+	}
+	if i < len(track) {
+		after = &track[i]
+	}
+
+	if before != nil && after != nil &&
+		after.Time.Sub(before.Time) <= maxGap {
+		ratio := float64(t.Sub(before.Time)) /
+			float64(after.Time.Sub(before.Time))
+		interpolate := func(a, b float64) float64 {
+			return a + (b-a)*ratio
+		}
+		return &GpsLocation{
+			Lat:  interpolate(before.Lat, after.Lat),
+			Long: interpolate(before.Long, after.Long),
+			Alt:  interpolate(before.Alt, after.Alt),
+		}, before, after
+	}
+
+	// Nearest point within the gap.
+	var nearest *TrackPoint
+	if before != nil && t.Sub(before.Time) <= maxGap {
+		nearest = before
+	}
+	if after != nil && after.Time.Sub(t) <= maxGap &&
+		(nearest == nil || after.Time.Sub(t) < t.Sub(before.Time)) {
+		nearest = after
+	}
+	if nearest == nil {
+		return nil, nil, nil
+	}
+	return &GpsLocation{
+		Lat:  nearest.Lat,
+		Long: nearest.Long,
+		Alt:  nearest.Alt,
+	}, nearest, nil
+}
+
+// Locate the frames without location from the track, adding a
+// GpsLocation for each. Return what was located.
+func (db *E4fDb) Geotag(track []TrackPoint, options GeotagOptions) (results []GeotagResult) {
+	track = append([]TrackPoint(nil), track...)
+	sort.SliceStable(track, func(i, j int) bool {
+		return track[i].Time.Before(track[j].Time)
+	})
+
+	nextId := 1
+	for _, gps := range db.GpsLocations {
+		if gps.Id >= nextId {
+			nextId = gps.Id + 1
+		}
+	}
+
+	for _, roll := range db.ExposedRolls {
+		loc := options.Location
+		if rollLoc, found := options.RollLocations[roll.Id]; found {
+			loc = rollLoc
+		}
+		for _, frame := range db.FramesForRoll(roll) {
+			if frame.Gps != nil {
+				continue
+			}
+			t, ok := frame.UTCTime(loc)
+			if !ok {
+				continue
+			}
+			t = t.Add(options.Offset)
+			gps, before, after := locateOnTrack(track, t, options.MaxGap)
+			if gps == nil {
+				continue
+			}
+			gps.Id = nextId
+			nextId++
+			db.GpsLocations = append(db.GpsLocations, gps)
+			frame.Exposure.GpsLocId = gps.Id
+			results = append(results, GeotagResult{
+				Roll:   roll,
+				Frame:  frame.Number(),
+				Time:   t,
+				Gps:    gps,
+				Before: before,
+				After:  after,
+			})
+		}
+	}
+
+	db.buildMaps()
+	return
+}
@@ -0,0 +1,95 @@
+package e4f
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The datum of the phone GPS.
+const GpsMapDatum = "WGS-84"
+
+// The Exif GPSVersionID written.
+var GpsVersionID = [4]byte{2, 3, 0, 0}
+
+// Default number of decimals for the minutes of a GPS coordinate.
+const DefaultGpsPrecision = 6
+
+// Return the GPS ref for the coordinate f. dir is either 'N' or 'E'
+// and the sign will change it.
+func GpsRef(f float64, dir byte) byte {
+	if math.Signbit(f) {
+		switch dir {
+		case 'N':
+			return 'S'
+		case 'E':
+			return 'W'
+		}
+	}
+	return dir
+}
+
+// Format a coordinate as a XMP GPSCoordinate "DDD,MM.mmk". dir is
+// either 'N' or 'E'. precision is the number of decimals of the
+// minutes.
+func FormatGpsCoord(f float64, dir byte, precision int) string {
+	dir = GpsRef(f, dir)
+	f = math.Abs(f)
+	degs := math.Floor(f)
+	minutes := (f - degs) * 60
+
+	// Rounding may carry to the degrees.
+	scale := math.Pow(10, float64(precision))
+	if math.Round(minutes*scale)/scale >= 60 {
+		degs++
+		minutes = 0
+	}
+	return fmt.Sprintf("%d,%.*f%c", int(degs), precision, minutes, dir)
+}
+
+// Parse a XMP GPSCoordinate, either "DDD,MM.mmk" or "DDD,MM,SSk".
+func ParseGpsCoord(s string) (float64, error) {
+	if len(s) < 2 {
+		return 0, fmt.Errorf("invalid GPS coordinate %q", s)
+	}
+	dir := s[len(s)-1]
+	parts := strings.Split(s[:len(s)-1], ",")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid GPS coordinate %q", s)
+	}
+
+	var f float64
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid GPS coordinate %q", s)
+		}
+		f += v / math.Pow(60, float64(i))
+	}
+	switch dir {
+	case 'S', 'W':
+		f = -f
+	case 'N', 'E':
+	default:
+		return 0, fmt.Errorf("invalid GPS coordinate %q", s)
+	}
+	return f, nil
+}
+
+// Return the altitude in tenth of meters, always positive, and the
+// Exif GPSAltitudeRef, 1 for below sea level.
+func GpsAltitude(alt float64) (tenths int, ref int) {
+	if alt < 0 {
+		ref = 1
+	}
+	return int(math.Round(math.Abs(alt) * 10)), ref
+}
+
+// Return the UTC time the frame was taken, the timestamp being local
+// to loc.
+func (f *Frame) UTCTime(loc *time.Location) (t time.Time, ok bool) {
+	t, ok = f.TimeIn(loc)
+	return t.UTC(), ok
+}
@@ -0,0 +1,190 @@
+package e4f
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	}
+	return "error"
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// A problem found in the data.
+type Finding struct {
+	Severity Severity `json:"severity"`
+	// Name of the check.
+	Check string `json:"check"`
+	Roll  int    `json:"roll"`
+	// Frame number, 1 based. 0 for the roll.
+	Frame   int    `json:"frame,omitempty"`
+	Message string `json:"message"`
+}
+
+func (f Finding) String() string {
+	where := fmt.Sprintf("roll %d", f.Roll)
+	if f.Frame > 0 {
+		where += fmt.Sprintf(", frame %d", f.Frame)
+	}
+	return fmt.Sprintf("%s: %s: %s [%s]", where, f.Severity, f.Message,
+		f.Check)
+}
+
+type linter struct {
+	roll     *ExposedRoll
+	findings []Finding
+}
+
+func (l *linter) report(severity Severity, check string, frame int,
+	format string, args ...interface{}) {
+	l.findings = append(l.findings, Finding{
+		Severity: severity,
+		Check:    check,
+		Roll:     l.roll.Id,
+		Frame:    frame,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// Check the frame against its lens.
+func (l *linter) lintLens(frame *Frame) {
+	lens := frame.Lens
+	if lens == nil {
+		return
+	}
+	n := frame.Number()
+	focal := frame.Exposure.FocalLength
+	if focal != 0 && lens.FocalLengthMin != 0 && lens.FocalLengthMax != 0 &&
+		(focal < lens.FocalLengthMin || focal > lens.FocalLengthMax) {
+		l.report(SeverityError, "focal-length", n,
+			"focal length %gmm outside of %s %g-%gmm", focal,
+			frame.LensDescription(), lens.FocalLengthMin,
+			lens.FocalLengthMax)
+	}
+
+	aperture, ok := frame.Aperture()
+	if !ok {
+		return
+	}
+	widest, narrowest, ok := frame.LensApertures()
+	if !ok {
+		return
+	}
+	if aperture < widest {
+		l.report(SeverityError, "aperture", n,
+			"aperture f/%g wider than f/%g of %s", aperture, widest,
+			frame.LensDescription())
+	} else if aperture > narrowest {
+		l.report(SeverityError, "aperture", n,
+			"aperture f/%g narrower than f/%g of %s", aperture,
+			narrowest, frame.LensDescription())
+	}
+}
+
+// Parse a roll time, ok is false if empty or invalid.
+func parseRollTime(s string) (time.Time, bool) {
+	if s == "" {
+		return time.Time{}, false
+	}
+	t, err := ParseTime(s)
+	return t, err == nil
+}
+
+func (l *linter) lintTimes(frames []*Frame) {
+	loaded, hasLoaded := parseRollTime(l.roll.TimeLoaded)
+	unloaded, hasUnloaded := parseRollTime(l.roll.TimeUnloaded)
+
+	// In the order of the exposure numbers, as EstimateMissing.
+	frames = append([]*Frame(nil), frames...)
+	sort.SliceStable(frames, func(i, j int) bool {
+		return frames[i].Exposure.Number < frames[j].Exposure.Number
+	})
+
+	var previous *Frame
+	var previousTime time.Time
+	for _, frame := range frames {
+		t, ok := frame.Time()
+		if !ok {
+			continue
+		}
+		n := frame.Number()
+		if hasLoaded && t.Before(loaded) {
+			l.report(SeverityWarning, "before-loaded", n,
+				"taken %s, before the roll was loaded %s",
+				t.Format(timeLayout), loaded.Format(timeLayout))
+		}
+		if hasUnloaded && t.After(unloaded) {
+			l.report(SeverityWarning, "after-unloaded", n,
+				"taken %s, after the roll was unloaded %s",
+				t.Format(timeLayout), unloaded.Format(timeLayout))
+		}
+		// Without number, the order is unknown.
+		if frame.Exposure.Number <= 0 {
+			continue
+		}
+		if previous != nil &&
+			previous.Exposure.Number < frame.Exposure.Number &&
+			t.Before(previousTime) {
+			l.report(SeverityWarning, "time-order", n,
+				"taken %s, before exposure %d at %s",
+				t.Format(timeLayout), previous.Exposure.Number,
+				previousTime.Format(timeLayout))
+		}
+		previous, previousTime = frame, t
+	}
+}
+
+func (l *linter) lintRoll(frames []*Frame, film *Film) {
+	roll := l.roll
+	if roll.FrameCount > 0 && len(frames) > roll.FrameCount {
+		l.report(SeverityWarning, "frame-count", 0,
+			"%d exposures for %d frames", len(frames), roll.FrameCount)
+	}
+
+	numbers := make(map[int]int)
+	for _, frame := range frames {
+		number := frame.Exposure.Number
+		if first, found := numbers[number]; found {
+			l.report(SeverityError, "duplicate-number", frame.Number(),
+				"exposure number %d already used by frame %d",
+				number, first)
+			continue
+		}
+		numbers[number] = frame.Number()
+	}
+
+	if film != nil && film.Iso != 0 && roll.Iso != 0 && film.Iso != roll.Iso {
+		l.report(SeverityInfo, "iso", 0,
+			"roll exposed at ISO %d, film is ISO %d", roll.Iso, film.Iso)
+	}
+}
+
+// Check the roll for impossible or suspicious data.
+func (db *E4fDb) LintRoll(roll *ExposedRoll) []Finding {
+	l := &linter{roll: roll}
+	frames := db.FramesForRoll(roll)
+	l.lintRoll(frames, db.FilmMap[roll.FilmId])
+	for _, frame := range frames {
+		l.lintLens(frame)
+	}
+	l.lintTimes(frames)
+	return l.findings
+}
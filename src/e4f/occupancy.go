@@ -0,0 +1,175 @@
+package e4f
+
+import (
+	"sort"
+	"time"
+)
+
+// When a roll was in its camera. Without the loaded or unloaded time,
+// the first or last frame time is used. Without both unloaded and
+// frame times, the roll was unloaded when the next one was loaded, and
+// Unloaded is zero for the last roll: it is still in the camera.
+type Occupancy struct {
+	Roll             *ExposedRoll
+	Loaded, Unloaded time.Time
+	frames           []*Frame
+	frameTimes       []time.Time
+}
+
+// Two rolls in the same camera at the same time.
+type Overlap struct {
+	First, Second *Occupancy
+	Start, End    time.Time
+	// The frames taken during the overlap.
+	Disputed []DisputedFrame
+}
+
+// A frame taken while two rolls were in the camera.
+type DisputedFrame struct {
+	Roll  *ExposedRoll
+	Frame int
+	Time  time.Time
+	// The roll it most likely belongs to, with the frame taken the
+	// closest in time.
+	Owner *ExposedRoll
+}
+
+// A time the camera was empty.
+type Gap struct {
+	Before, After *Occupancy
+}
+
+// The rolls of a camera, in the order they were loaded.
+type CameraTimeline struct {
+	Camera   *Camera
+	Rolls    []*Occupancy
+	Overlaps []*Overlap
+	Gaps     []Gap
+}
+
+func (db *E4fDb) occupancy(roll *ExposedRoll) *Occupancy {
+	o := &Occupancy{Roll: roll}
+	for _, frame := range db.FramesForRoll(roll) {
+		if t, ok := frame.Time(); ok {
+			o.frames = append(o.frames, frame)
+			o.frameTimes = append(o.frameTimes, t)
+		}
+	}
+	var hasLoaded, hasUnloaded bool
+	o.Loaded, hasLoaded = parseRollTime(roll.TimeLoaded)
+	o.Unloaded, hasUnloaded = parseRollTime(roll.TimeUnloaded)
+	for _, t := range o.frameTimes {
+		if !hasLoaded && (o.Loaded.IsZero() || t.Before(o.Loaded)) {
+			o.Loaded = t
+		}
+		if !hasUnloaded && (o.Unloaded.IsZero() || t.After(o.Unloaded)) {
+			o.Unloaded = t
+		}
+	}
+	return o
+}
+
+// Return how far t is from the closest frame of o, except exclude.
+func (o *Occupancy) distance(t time.Time, exclude *Frame) (time.Duration, bool) {
+	var best time.Duration
+	found := false
+	for i, ft := range o.frameTimes {
+		if o.frames[i] == exclude {
+			continue
+		}
+		d := t.Sub(ft)
+		if d < 0 {
+			d = -d
+		}
+		if !found || d < best {
+			best, found = d, true
+		}
+	}
+	return best, found
+}
+
+func (ov *Overlap) findDisputed() {
+	for _, o := range []*Occupancy{ov.First, ov.Second} {
+		other := ov.Second
+		if o == ov.Second {
+			other = ov.First
+		}
+		for i, frame := range o.frames {
+			t := o.frameTimes[i]
+			if t.Before(ov.Start) || t.After(ov.End) {
+				continue
+			}
+			disputed := DisputedFrame{
+				Roll:  o.Roll,
+				Frame: frame.Number(),
+				Time:  t,
+				Owner: o.Roll,
+			}
+			own, hasOwn := o.distance(t, frame)
+			theirs, hasTheirs := other.distance(t, nil)
+			if hasTheirs && (!hasOwn || theirs < own) {
+				disputed.Owner = other.Roll
+			}
+			ov.Disputed = append(ov.Disputed, disputed)
+		}
+	}
+	sort.SliceStable(ov.Disputed, func(i, j int) bool {
+		return ov.Disputed[i].Time.Before(ov.Disputed[j].Time)
+	})
+}
+
+// Build the timeline of each camera, with the overlapping rolls and
+// the gaps between them. Rolls without any time are ignored.
+func (db *E4fDb) CameraTimelines() (timelines []*CameraTimeline) {
+	for _, camera := range db.Cameras {
+		timeline := &CameraTimeline{Camera: camera}
+		for _, roll := range db.ExposedRolls {
+			if roll.CameraId != camera.Id {
+				continue
+			}
+			o := db.occupancy(roll)
+			if o.Loaded.IsZero() {
+				continue
+			}
+			timeline.Rolls = append(timeline.Rolls, o)
+		}
+		sort.SliceStable(timeline.Rolls, func(i, j int) bool {
+			return timeline.Rolls[i].Loaded.Before(timeline.Rolls[j].Loaded)
+		})
+		for i, o := range timeline.Rolls {
+			if o.Unloaded.IsZero() && i+1 < len(timeline.Rolls) {
+				o.Unloaded = timeline.Rolls[i+1].Loaded
+			}
+		}
+
+		// The roll unloaded the latest so far.
+		var last *Occupancy
+		for i, o := range timeline.Rolls {
+			for _, previous := range timeline.Rolls[:i] {
+				if previous.Unloaded.After(o.Loaded) {
+					end := o.Unloaded
+					if end.IsZero() || previous.Unloaded.Before(end) {
+						end = previous.Unloaded
+					}
+					overlap := &Overlap{
+						First:  previous,
+						Second: o,
+						Start:  o.Loaded,
+						End:    end,
+					}
+					overlap.findDisputed()
+					timeline.Overlaps = append(timeline.Overlaps,
+						overlap)
+				}
+			}
+			if last != nil && last.Unloaded.Before(o.Loaded) {
+				timeline.Gaps = append(timeline.Gaps, Gap{last, o})
+			}
+			if last == nil || o.Unloaded.After(last.Unloaded) {
+				last = o
+			}
+		}
+		timelines = append(timelines, timeline)
+	}
+	return
+}
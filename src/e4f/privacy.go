@@ -0,0 +1,158 @@
+package e4f
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// A zone where locations are private.
+type PrivacyZone struct {
+	Name string  `json:"name"`
+	Lat  float64 `json:"lat"`
+	Long float64 `json:"long"`
+	// Radius in meters.
+	Radius float64 `json:"radius"`
+	// Snap the locations to the center instead of dropping them.
+	Snap bool `json:"snap"`
+}
+
+// What to remove or blur before exporting.
+type Privacy struct {
+	Zones []PrivacyZone `json:"zones"`
+	// Size of the grid, in degrees, the coordinates are snapped
+	// to. 0 to keep them as is.
+	FuzzGrid float64 `json:"fuzz"`
+
+	StripGps          bool `json:"-"`
+	StripSerials      bool `json:"-"`
+	StripDescriptions bool `json:"-"`
+}
+
+// Load the privacy zones and fuzzing from a JSON file.
+func LoadPrivacy(path string) (*Privacy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	privacy := &Privacy{}
+	if err = json.Unmarshal(data, privacy); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return privacy, nil
+}
+
+// Set what to strip from a comma separated list of gps, serials and
+// descriptions.
+func (p *Privacy) SetStrip(list string) error {
+	for _, item := range strings.Split(list, ",") {
+		switch strings.TrimSpace(item) {
+		case "":
+		case "gps":
+			p.StripGps = true
+		case "serials":
+			p.StripSerials = true
+		case "descriptions":
+			p.StripDescriptions = true
+		default:
+			return fmt.Errorf("unknown item to strip %q", item)
+		}
+	}
+	return nil
+}
+
+const earthRadius = 6371008.8
+
+// Distance in meters between two coordinates.
+func Distance(lat1, long1, lat2, long2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLong := (long2 - long1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*
+			math.Sin(dLong/2)*math.Sin(dLong/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// Return the zone containing the location, or nil.
+func (p *Privacy) zoneFor(gps *GpsLocation) *PrivacyZone {
+	for i := range p.Zones {
+		zone := &p.Zones[i]
+		if Distance(zone.Lat, zone.Long, gps.Lat, gps.Long) <=
+			zone.Radius {
+			return zone
+		}
+	}
+	return nil
+}
+
+func fuzz(f float64, grid float64) float64 {
+	return math.Round(f/grid) * grid
+}
+
+// Hide the location. Return false if it must be dropped.
+func (p *Privacy) hide(gps *GpsLocation) bool {
+	if p.StripGps {
+		return false
+	}
+	if zone := p.zoneFor(gps); zone != nil {
+		if !zone.Snap {
+			return false
+		}
+		gps.Lat = zone.Lat
+		gps.Long = zone.Long
+		gps.Alt = 0
+	} else if p.FuzzGrid > 0 {
+		gps.Lat = fuzz(gps.Lat, p.FuzzGrid)
+		gps.Long = fuzz(gps.Long, p.FuzzGrid)
+	}
+	return true
+}
+
+// Apply the privacy settings to the database. Dropped locations are
+// removed, and the exposures referencing them lose their location.
+func (db *E4fDb) ApplyPrivacy(p *Privacy) {
+	var kept []*GpsLocation
+	dropped := make(map[int]bool)
+	for _, gps := range db.GpsLocations {
+		if !p.hide(gps) {
+			dropped[gps.Id] = true
+			continue
+		}
+		kept = append(kept, gps)
+	}
+	db.GpsLocations = kept
+
+	for _, estimate := range db.Estimates {
+		if estimate.Gps != nil && !p.hide(estimate.Gps) {
+			estimate.Gps = nil
+		}
+	}
+
+	for _, exp := range db.Exposures {
+		if dropped[exp.GpsLocId] {
+			exp.GpsLocId = 0
+		}
+		if p.StripDescriptions {
+			exp.Desc = ""
+		}
+	}
+	if p.StripDescriptions {
+		for _, roll := range db.ExposedRolls {
+			roll.Desc = ""
+		}
+	}
+
+	if p.StripSerials {
+		for _, camera := range db.Cameras {
+			camera.SerialNumber = ""
+		}
+		for _, lens := range db.Lenses {
+			lens.SerialNumber = ""
+		}
+	}
+
+	db.buildMaps()
+}
@@ -0,0 +1,80 @@
+package e4f
+
+import (
+	"fmt"
+	"math"
+)
+
+// Exif SensitivityType values
+const (
+	SensitivityUnknown                  = 0
+	SensitivityRecommendedExposureIndex = 2
+	SensitivityISOSpeed                 = 3
+)
+
+// Return the stops the roll was pushed, negative if pulled, rounded
+// to a third. ok is false if either speed is unknown.
+func PushPull(filmIso int, rollIso int) (stops float64, ok bool) {
+	if filmIso <= 0 || rollIso <= 0 {
+		return 0, false
+	}
+	stops = math.Log2(float64(rollIso) / float64(filmIso))
+	return math.Round(stops*3) / 3, true
+}
+
+// Format stops like "+2", "-1/3" or "+1 2/3".
+func FormatStops(stops float64) string {
+	thirds := int(math.Round(math.Abs(stops) * 3))
+	sign := "+"
+	if stops < 0 {
+		sign = "-"
+	}
+	whole, rem := thirds/3, thirds%3
+	switch {
+	case rem == 0:
+		return fmt.Sprintf("%s%d", sign, whole)
+	case whole == 0:
+		return fmt.Sprintf("%s%d/3", sign, rem)
+	}
+	return fmt.Sprintf("%s%d %d/3", sign, whole, rem)
+}
+
+// Describe the push or pull, like "Tri-X 400 pushed +2 to 1600".
+// Empty if at box speed or unknown.
+func pushPullLabel(label string, filmIso int, rollIso int) string {
+	stops, ok := PushPull(filmIso, rollIso)
+	if !ok || stops == 0 {
+		return ""
+	}
+	verb := "pushed"
+	if stops < 0 {
+		verb = "pulled"
+	}
+	if label == "" {
+		label = fmt.Sprintf("ISO %d", filmIso)
+	}
+	return fmt.Sprintf("%s %s %s to %d", label, verb, FormatStops(stops),
+		rollIso)
+}
+
+// Return the stops the roll of the frame was pushed, negative if
+// pulled.
+func (f *Frame) PushPull() (stops float64, ok bool) {
+	if f.Film == nil {
+		return 0, false
+	}
+	return PushPull(f.Film.Iso, f.Roll.Iso)
+}
+
+// The Exif SensitivityType of the roll ISO: the ISO speed at box
+// speed, else an exposure index.
+func (f *Frame) SensitivityType() int {
+	stops, ok := f.PushPull()
+	switch {
+	case !ok:
+		return SensitivityUnknown
+	case stops == 0:
+		return SensitivityISOSpeed
+	}
+	return SensitivityRecommendedExposureIndex
+}
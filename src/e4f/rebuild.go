@@ -0,0 +1,242 @@
+package e4f
+
+import (
+	"sort"
+	"strings"
+)
+
+// A frame as read back from the metadata of a scan, to rebuild the
+// database. Empty values are unknown.
+type FrameRecord struct {
+	// Frames with the same RollKey are in the same roll.
+	RollKey string
+
+	Number       int
+	Desc         string
+	Artists      []string
+	TimeTaken    string
+	ShutterSpeed string
+	Aperture     string
+	FocalLength  float64
+	FlashOn      bool
+	MeteringMode string
+	LightSource  string
+	Gps          *GpsLocation
+
+	CameraMake              string
+	CameraModel             string
+	CameraSerialNumber      string
+	CameraDefaultFilmType   string
+	CameraDefaultFrameCount int
+
+	Lens               string
+	LensSerialNumber   string
+	LensFocalLengthMin float64
+	LensFocalLengthMax float64
+	LensApertureMin    string
+	LensApertureMax    string
+
+	RollDesc       string
+	RollFrameCount int
+	RollLoaded     string
+	RollUnloaded   string
+	Iso            int
+	FilmType       string
+	FilmMake       string
+	// The film label, with or without the maker name.
+	Film          string
+	FilmProcess   string
+	FilmColorType string
+	FilmIso       int
+}
+
+// Builds the database, sharing the entities between the frames.
+type rebuilder struct {
+	db      *E4fDb
+	makes   map[string]*Make
+	cameras map[[3]string]*Camera
+	lenses  map[[2]string]*Lens
+	films   map[[2]string]*Film
+}
+
+func (r *rebuilder) make(name string) int {
+	if name == "" {
+		return 0
+	}
+	if mk, found := r.makes[name]; found {
+		return mk.Id
+	}
+	mk := &Make{Id: len(r.db.Makes) + 1, Name: name}
+	r.db.Makes = append(r.db.Makes, mk)
+	r.makes[name] = mk
+	return mk.Id
+}
+
+func (r *rebuilder) camera(rec *FrameRecord) int {
+	if rec.CameraMake == "" && rec.CameraModel == "" {
+		return 0
+	}
+	key := [3]string{rec.CameraMake, rec.CameraModel,
+		rec.CameraSerialNumber}
+	if camera, found := r.cameras[key]; found {
+		return camera.Id
+	}
+	camera := &Camera{
+		Id:                len(r.db.Cameras) + 1,
+		MakeId:            r.make(rec.CameraMake),
+		Title:             rec.CameraModel,
+		SerialNumber:      rec.CameraSerialNumber,
+		DefaultFilmType:   rec.CameraDefaultFilmType,
+		DefaultFrameCount: rec.CameraDefaultFrameCount,
+	}
+	r.db.Cameras = append(r.db.Cameras, camera)
+	r.cameras[key] = camera
+	return camera.Id
+}
+
+func (r *rebuilder) lens(rec *FrameRecord) int {
+	if rec.Lens == "" {
+		return 0
+	}
+	key := [2]string{rec.Lens, rec.LensSerialNumber}
+	if lens, found := r.lenses[key]; found {
+		return lens.Id
+	}
+	lens := &Lens{
+		Id:             len(r.db.Lenses) + 1,
+		Title:          rec.Lens,
+		SerialNumber:   rec.LensSerialNumber,
+		FocalLengthMin: rec.LensFocalLengthMin,
+		FocalLengthMax: rec.LensFocalLengthMax,
+		ApertureMin:    rec.LensApertureMin,
+		ApertureMax:    rec.LensApertureMax,
+	}
+	r.db.Lenses = append(r.db.Lenses, lens)
+	r.lenses[key] = lens
+	return lens.Id
+}
+
+func (r *rebuilder) film(rec *FrameRecord) int {
+	title := strings.TrimSpace(strings.TrimPrefix(rec.Film, rec.FilmMake))
+	if title == "" {
+		return 0
+	}
+	key := [2]string{rec.FilmMake, title}
+	if film, found := r.films[key]; found {
+		return film.Id
+	}
+	film := &Film{
+		Id:        len(r.db.Films) + 1,
+		MakeId:    r.make(rec.FilmMake),
+		Title:     title,
+		Process:   rec.FilmProcess,
+		ColorType: rec.FilmColorType,
+		Iso:       rec.FilmIso,
+	}
+	r.db.Films = append(r.db.Films, film)
+	r.films[key] = film
+	return film.Id
+}
+
+// Fill what the roll doesn't know yet from the frame.
+func (r *rebuilder) fillRoll(roll *ExposedRoll, rec *FrameRecord) {
+	if roll.Desc == "" {
+		roll.Desc = rec.RollDesc
+	}
+	if roll.CameraId == 0 {
+		roll.CameraId = r.camera(rec)
+	}
+	if roll.FilmId == 0 {
+		roll.FilmId = r.film(rec)
+	}
+	if roll.FilmType == "" {
+		roll.FilmType = denormalizeFilmType(rec.FilmType)
+	}
+	if roll.Iso == 0 {
+		roll.Iso = rec.Iso
+	}
+	if roll.FrameCount == 0 {
+		roll.FrameCount = rec.RollFrameCount
+	}
+	if roll.TimeLoaded == "" {
+		roll.TimeLoaded = rec.RollLoaded
+	}
+	if roll.TimeUnloaded == "" {
+		roll.TimeUnloaded = rec.RollUnloaded
+	}
+}
+
+// The e4f film type of a normalized one, see NormalizeFilmType.
+func denormalizeFilmType(filmType string) string {
+	switch filmType {
+	case "120", "220", "135":
+		return "F" + filmType
+	}
+	return filmType
+}
+
+// Rebuild a database from the frames read back from their scans. The
+// rolls are in the order of their first frame, the frames of a roll in
+// number order.
+func Rebuild(records []*FrameRecord) *E4fDb {
+	r := &rebuilder{
+		db:      &E4fDb{},
+		makes:   make(map[string]*Make),
+		cameras: make(map[[3]string]*Camera),
+		lenses:  make(map[[2]string]*Lens),
+		films:   make(map[[2]string]*Film),
+	}
+	db := r.db
+	db.FrameArtists = make(map[int][]*Artist)
+
+	var keys []string
+	rolls := make(map[string][]*FrameRecord)
+	for _, rec := range records {
+		if _, found := rolls[rec.RollKey]; !found {
+			keys = append(keys, rec.RollKey)
+		}
+		rolls[rec.RollKey] = append(rolls[rec.RollKey], rec)
+	}
+
+	for _, key := range keys {
+		frames := rolls[key]
+		// Unknown numbers last, in the order read.
+		sort.SliceStable(frames, func(i, j int) bool {
+			a, b := frames[i].Number, frames[j].Number
+			return a != 0 && (b == 0 || a < b)
+		})
+		roll := &ExposedRoll{Id: len(db.ExposedRolls) + 1}
+		db.ExposedRolls = append(db.ExposedRolls, roll)
+		for _, rec := range frames {
+			r.fillRoll(roll, rec)
+			exp := &Exposure{
+				Id:           len(db.Exposures) + 1,
+				RollId:       roll.Id,
+				Number:       rec.Number,
+				Desc:         rec.Desc,
+				TimeTaken:    rec.TimeTaken,
+				ShutterSpeed: rec.ShutterSpeed,
+				Aperture:     rec.Aperture,
+				FocalLength:  rec.FocalLength,
+				FlashOn:      rec.FlashOn,
+				MeteringMode: rec.MeteringMode,
+				LightSource:  rec.LightSource,
+				LensId:       r.lens(rec),
+			}
+			if rec.Gps != nil {
+				gps := *rec.Gps
+				gps.Id = len(db.GpsLocations) + 1
+				db.GpsLocations = append(db.GpsLocations, &gps)
+				exp.GpsLocId = gps.Id
+			}
+			db.Exposures = append(db.Exposures, exp)
+			for _, name := range rec.Artists {
+				db.FrameArtists[exp.Id] = append(
+					db.FrameArtists[exp.Id], db.artist(strings.TrimSpace(name)))
+			}
+		}
+	}
+
+	db.buildMaps()
+	return db
+}
@@ -0,0 +1,53 @@
+package e4f
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Exif4Film timestamps look like "2013-06-30T17:51:53Z181".
+// Despite the 'Z' they are the local time of the phone, without
+// any offset, and the trailing number is the day of the year.
+const timeLayout = "2006-01-02T15:04:05"
+
+// Layout of EXIF date time.
+const ExifTimeLayout = "2006:01:02 15:04:05"
+
+// Parse an Exif4Film timestamp. As there is no time zone, the
+// result is the wall time in UTC.
+func ParseTime(s string) (time.Time, error) {
+	if idx := strings.IndexByte(s, 'Z'); idx >= 0 {
+		s = s[:idx]
+	}
+	return time.Parse(timeLayout, s)
+}
+
+// Format t as an Exif4Film timestamp. Only the wall time is used.
+func FormatTime(t time.Time) string {
+	return fmt.Sprintf("%sZ%d", t.Format(timeLayout), t.YearDay())
+}
+
+// Return the time the frame was taken, possibly estimated. ok is
+// false if unknown.
+func (f *Frame) Time() (t time.Time, ok bool) {
+	if f.TimeEstimated {
+		return f.estimatedTime, true
+	}
+	if f.Exposure.TimeTaken == "" {
+		return
+	}
+	t, err := ParseTime(f.Exposure.TimeTaken)
+	return t, err == nil
+}
+
+// Return the time the frame was taken, the timestamp being local to
+// loc.
+func (f *Frame) TimeIn(loc *time.Location) (t time.Time, ok bool) {
+	t, ok = f.Time()
+	if !ok {
+		return
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(),
+		t.Second(), t.Nanosecond(), loc), true
+}
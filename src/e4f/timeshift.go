@@ -0,0 +1,153 @@
+package e4f
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// A correction of the phone clock for a roll, or some of its frames.
+// Either Offset is set, or Frame and Time.
+type TimeShift struct {
+	Roll int `json:"roll"`
+	// Exposure numbers. Empty for the whole roll, including the time it
+	// was loaded and unloaded.
+	Frames []int `json:"frames,omitempty"`
+	// Added to the times, like "-1h30m".
+	Offset string `json:"offset,omitempty"`
+	// An exposure number and the real time it was taken, like
+	// "2013-06-30T17:51:53".
+	Frame int    `json:"frame,omitempty"`
+	Time  string `json:"time,omitempty"`
+}
+
+// The corrections to apply over an export.
+type TimeShifts struct {
+	Shifts []TimeShift `json:"shifts"`
+}
+
+// Load the time corrections from a JSON file.
+func LoadTimeShifts(path string) (*TimeShifts, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	shifts := &TimeShifts{}
+	if err = json.Unmarshal(data, shifts); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return shifts, nil
+}
+
+// Shift the Exif4Film timestamp s by d. The wall time is local to
+// loc, so that across a DST change the shift stays the same amount of
+// real time.
+func shiftTime(s string, d time.Duration, loc *time.Location) (string, error) {
+	if s == "" {
+		return s, nil
+	}
+	t, err := ParseTime(s)
+	if err != nil {
+		return s, err
+	}
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(),
+		t.Second(), t.Nanosecond(), loc)
+	t = t.Add(d).In(loc)
+	// Back to the wall time.
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(),
+		t.Second(), t.Nanosecond(), time.UTC)
+	return FormatTime(t), nil
+}
+
+// Return the exposure numbered n, or nil.
+func exposureNumbered(exps []*Exposure, n int) *Exposure {
+	for _, exp := range exps {
+		if exp.Number == n {
+			return exp
+		}
+	}
+	return nil
+}
+
+// Return the offset of the shift, computed from the known frame if
+// needed.
+func (s *TimeShift) offset(exps []*Exposure, loc *time.Location) (time.Duration, error) {
+	if s.Offset != "" {
+		return time.ParseDuration(s.Offset)
+	}
+	if s.Frame == 0 || s.Time == "" {
+		return 0, fmt.Errorf("roll %d: needs an offset, or a frame and a time",
+			s.Roll)
+	}
+	known := exposureNumbered(exps, s.Frame)
+	if known == nil {
+		return 0, fmt.Errorf("roll %d: no frame %d", s.Roll, s.Frame)
+	}
+	actual, err := time.ParseInLocation(timeLayout, s.Time, loc)
+	if err != nil {
+		return 0, err
+	}
+	recorded, err := ParseTime(known.TimeTaken)
+	if err != nil {
+		return 0, fmt.Errorf("roll %d, frame %d: %w", s.Roll, s.Frame, err)
+	}
+	recorded = time.Date(recorded.Year(), recorded.Month(), recorded.Day(),
+		recorded.Hour(), recorded.Minute(), recorded.Second(),
+		recorded.Nanosecond(), loc)
+	return actual.Sub(recorded), nil
+}
+
+// Apply the time correction. The timestamps are local to loc.
+func (db *E4fDb) ApplyTimeShift(shift *TimeShift, loc *time.Location) error {
+	roll, found := db.RollMap[shift.Roll]
+	if !found {
+		return fmt.Errorf("unknown roll %d", shift.Roll)
+	}
+	exps := db.ExposuresForRoll(roll.Id)
+	d, err := shift.offset(exps, loc)
+	if err != nil {
+		return err
+	}
+
+	selected := exps
+	times := []*string{}
+	if len(shift.Frames) == 0 {
+		times = append(times, &roll.TimeLoaded, &roll.TimeUnloaded)
+	} else {
+		selected = nil
+		for _, n := range shift.Frames {
+			exp := exposureNumbered(exps, n)
+			if exp == nil {
+				return fmt.Errorf("roll %d: no frame %d", roll.Id, n)
+			}
+			selected = append(selected, exp)
+		}
+	}
+	for _, exp := range selected {
+		times = append(times, &exp.TimeTaken)
+	}
+
+	// All are shifted before any is changed, to leave the roll
+	// untouched on error.
+	shifted := make([]string, len(times))
+	for i, t := range times {
+		if shifted[i], err = shiftTime(*t, d, loc); err != nil {
+			return fmt.Errorf("roll %d: %w", roll.Id, err)
+		}
+	}
+	for i, t := range times {
+		*t = shifted[i]
+	}
+	return nil
+}
+
+// Apply all the time corrections.
+func (db *E4fDb) ApplyTimeShifts(shifts *TimeShifts, loc *time.Location) error {
+	for i := range shifts.Shifts {
+		if err := db.ApplyTimeShift(&shifts.Shifts[i], loc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,629 @@
+// Write EXIF into JPEG and TIFF files.
+//
+// In a TIFF file, an updated IFD is rewritten in place if it fits in
+// the space of the old one and of its values, else it is appended and
+// the pointers are changed to the new one. The untouched entries are
+// copied verbatim, and their values stay where they are, so the image
+// data is never moved. Neither are a maker note, the values of an
+// unknown type and what other IFDs reference.
+//
+// In a JPEG file, the TIFF structure of the EXIF segment is updated the
+// same way.
+//
+// See LICENSE
+
+package exif
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Tags in IFD0
+const (
+	TagImageDescription = 0x010e
+	TagMake             = 0x010f
+	TagModel            = 0x0110
+	TagArtist           = 0x013b
+	TagCopyright        = 0x8298
+	TagExifIFD          = 0x8769
+	TagGPSIFD           = 0x8825
+)
+
+// Tags in IFD1, the thumbnail
+const (
+	TagStripOffsets                = 0x0111
+	TagJPEGInterchangeFormat       = 0x0201
+	TagJPEGInterchangeFormatLength = 0x0202
+)
+
+// Tags in the Exif IFD
+const (
+	TagExposureTime             = 0x829a
+	TagFNumber                  = 0x829d
+	TagISOSpeedRatings          = 0x8827
+	TagSensitivityType          = 0x8830
+	TagRecommendedExposureIndex = 0x8832
+	TagExifVersion              = 0x9000
+	TagDateTimeOriginal         = 0x9003
+	TagDateTimeDigitized        = 0x9004
+	TagOffsetTimeOriginal       = 0x9011
+	TagMaxApertureValue         = 0x9205
+	TagMeteringMode             = 0x9207
+	TagLightSource              = 0x9208
+	TagFlash                    = 0x9209
+	TagFocalLength              = 0x920a
+	TagBodySerialNumber         = 0xa431
+	TagLensSpecification        = 0xa432
+	TagLensMake                 = 0xa433
+	TagLensModel                = 0xa434
+	TagLensSerialNumber         = 0xa435
+	TagInteropIFD               = 0xa005
+	TagMakerNote                = 0x927c
+)
+
+// Tags in the GPS IFD
+const (
+	TagGPSVersionID    = 0x0000
+	TagGPSLatitudeRef  = 0x0001
+	TagGPSLatitude     = 0x0002
+	TagGPSLongitudeRef = 0x0003
+	TagGPSLongitude    = 0x0004
+	TagGPSAltitudeRef  = 0x0005
+	TagGPSAltitude     = 0x0006
+	TagGPSTimeStamp    = 0x0007
+	TagGPSMapDatum     = 0x0012
+	TagGPSDateStamp    = 0x001d
+)
+
+// TIFF field types
+const (
+	TypeByte      = 1
+	TypeAscii     = 2
+	TypeShort     = 3
+	TypeLong      = 4
+	TypeRational  = 5
+	TypeSByte     = 6
+	TypeUndefined = 7
+	TypeSShort    = 8
+	TypeSLong     = 9
+	TypeSRational = 10
+	TypeFloat     = 11
+	TypeDouble    = 12
+	TypeIFD       = 13
+)
+
+var (
+	ErrNotTiff  = errors.New("exif: not a TIFF structure")
+	ErrNotJpeg  = errors.New("exif: not a JPEG file")
+	ErrTooLarge = errors.New("exif: EXIF too large for a JPEG segment")
+	// For the files that are neither JPEG nor TIFF, like PNG.
+	ErrUnsupported = errors.New("exif: not a JPEG or TIFF file")
+)
+
+// A Value to write in a tag.
+type Value struct {
+	Type uint16
+	// For TypeAscii
+	Text string
+	// For TypeShort, TypeLong and TypeSLong
+	Ints []int64
+	// For TypeRational and TypeSRational, numerator and denominator
+	// pairs.
+	Rationals [][2]int64
+	// For TypeByte and TypeUndefined
+	Bytes []byte
+}
+
+func Ascii(s string) Value {
+	return Value{Type: TypeAscii, Text: s}
+}
+
+func Short(v ...uint16) Value {
+	value := Value{Type: TypeShort}
+	for _, i := range v {
+		value.Ints = append(value.Ints, int64(i))
+	}
+	return value
+}
+
+func Long(v ...uint32) Value {
+	value := Value{Type: TypeLong}
+	for _, i := range v {
+		value.Ints = append(value.Ints, int64(i))
+	}
+	return value
+}
+
+func Byte(v ...byte) Value {
+	return Value{Type: TypeByte, Bytes: v}
+}
+
+func Undefined(v []byte) Value {
+	return Value{Type: TypeUndefined, Bytes: v}
+}
+
+// Rational from numerator and denominator pairs.
+func Rational(v ...[2]uint32) Value {
+	value := Value{Type: TypeRational}
+	for _, r := range v {
+		value.Rationals = append(value.Rationals,
+			[2]int64{int64(r[0]), int64(r[1])})
+	}
+	return value
+}
+
+// The number of items.
+func (v Value) count() uint32 {
+	switch v.Type {
+	case TypeAscii:
+		return uint32(len(v.Text)) + 1
+	case TypeRational, TypeSRational:
+		return uint32(len(v.Rationals))
+	case TypeByte, TypeUndefined:
+		return uint32(len(v.Bytes))
+	}
+	return uint32(len(v.Ints))
+}
+
+func (v Value) encode(order byteOrder) []byte {
+	var data []byte
+	switch v.Type {
+	case TypeAscii:
+		data = append([]byte(v.Text), 0)
+	case TypeByte, TypeUndefined:
+		data = v.Bytes
+	case TypeShort:
+		for _, i := range v.Ints {
+			data = order.AppendUint16(data, uint16(i))
+		}
+	case TypeLong, TypeSLong:
+		for _, i := range v.Ints {
+			data = order.AppendUint32(data, uint32(i))
+		}
+	case TypeRational, TypeSRational:
+		for _, r := range v.Rationals {
+			data = order.AppendUint32(data, uint32(r[0]))
+			data = order.AppendUint32(data, uint32(r[1]))
+		}
+	}
+	return data
+}
+
+// Tags to set, per IFD.
+type Tags struct {
+	IFD0 map[uint16]Value
+	Exif map[uint16]Value
+	GPS  map[uint16]Value
+}
+
+func NewTags() *Tags {
+	return &Tags{
+		IFD0: make(map[uint16]Value),
+		Exif: make(map[uint16]Value),
+		GPS:  make(map[uint16]Value),
+	}
+}
+
+type byteOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+// An IFD entry. raw is the 12 bytes as found in the file.
+type entry struct {
+	tag uint16
+	raw []byte
+}
+
+type tiffReader struct {
+	r     io.ReaderAt
+	order byteOrder
+}
+
+func (t *tiffReader) readIFD(offset uint32) (entries []entry, next uint32,
+	err error) {
+
+	var buf [12]byte
+	if _, err = t.r.ReadAt(buf[:2], int64(offset)); err != nil {
+		return
+	}
+	count := t.order.Uint16(buf[:2])
+	pos := int64(offset) + 2
+	for i := 0; i < int(count); i++ {
+		raw := make([]byte, 12)
+		if _, err = t.r.ReadAt(raw, pos); err != nil {
+			return
+		}
+		entries = append(entries, entry{t.order.Uint16(raw), raw})
+		pos += 12
+	}
+	if _, err = t.r.ReadAt(buf[:4], pos); err != nil {
+		return
+	}
+	next = t.order.Uint32(buf[:4])
+	return
+}
+
+var typeSizes = map[uint16]uint32{
+	TypeByte: 1, TypeAscii: 1, TypeShort: 2, TypeLong: 4,
+	TypeRational: 8, TypeSByte: 1, TypeUndefined: 1, TypeSShort: 2,
+	TypeSLong: 4, TypeSRational: 8, TypeFloat: 4, TypeDouble: 8,
+	TypeIFD: 4,
+}
+
+// The largest value read, in bytes.
+const maxValueSize = 1 << 24
+
+// Return the offset and the size of the value of the entry. The
+// offset is 0 if the value is in the entry. ok is false for an unknown
+// type.
+func (t *tiffReader) extent(e entry) (offset uint32, size uint32,
+	ok bool) {
+
+	typeSize, ok := typeSizes[t.order.Uint16(e.raw[2:])]
+	count := uint64(t.order.Uint32(e.raw[4:]))
+	if !ok || uint64(typeSize)*count > maxValueSize {
+		return 0, 0, false
+	}
+	size = typeSize * uint32(count)
+	if size > 4 {
+		offset = t.order.Uint32(e.raw[8:])
+	}
+	return offset, size, true
+}
+
+// Return the value bytes of the entry, and their offset as for extent.
+func (t *tiffReader) data(e entry) (data []byte, offset uint32,
+	ok bool) {
+
+	offset, size, ok := t.extent(e)
+	if !ok {
+		return nil, 0, false
+	}
+	data = make([]byte, size)
+	if offset == 0 {
+		copy(data, e.raw[8:])
+	} else if _, err := t.r.ReadAt(data, int64(offset)); err != nil {
+		return nil, 0, false
+	}
+	return data, offset, true
+}
+
+// Decode the value of the entry. Unsupported types are ignored.
+func (t *tiffReader) value(e entry) (value Value, ok bool) {
+	value.Type = t.order.Uint16(e.raw[2:])
+	data, _, ok := t.data(e)
+	if !ok {
+		return value, false
+	}
+
+	switch value.Type {
+	case TypeAscii:
+		value.Text = strings.TrimRight(string(data), "\x00")
+	case TypeByte, TypeUndefined:
+		value.Bytes = data
+	case TypeShort:
+		for i := 0; i < len(data); i += 2 {
+			value.Ints = append(value.Ints,
+				int64(t.order.Uint16(data[i:])))
+		}
+	case TypeLong:
+		for i := 0; i < len(data); i += 4 {
+			value.Ints = append(value.Ints,
+				int64(t.order.Uint32(data[i:])))
+		}
+	case TypeSLong:
+		for i := 0; i < len(data); i += 4 {
+			value.Ints = append(value.Ints,
+				int64(int32(t.order.Uint32(data[i:]))))
+		}
+	case TypeRational:
+		for i := 0; i < len(data); i += 8 {
+			value.Rationals = append(value.Rationals, [2]int64{
+				int64(t.order.Uint32(data[i:])),
+				int64(t.order.Uint32(data[i+4:]))})
+		}
+	case TypeSRational:
+		for i := 0; i < len(data); i += 8 {
+			value.Rationals = append(value.Rationals, [2]int64{
+				int64(int32(t.order.Uint32(data[i:]))),
+				int64(int32(t.order.Uint32(data[i+4:])))})
+		}
+	default:
+		return value, false
+	}
+	return value, true
+}
+
+// Decode the values of the entries into dst.
+func (t *tiffReader) values(entries []entry, dst map[uint16]Value) {
+	for _, e := range entries {
+		if value, ok := t.value(e); ok {
+			dst[e.tag] = value
+		}
+	}
+}
+
+// Read the tags of the TIFF structure in r.
+func read(r io.ReaderAt) (*Tags, error) {
+	order, offset, err := readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	t := &tiffReader{r, order}
+	tags := NewTags()
+	if offset == 0 {
+		return tags, nil
+	}
+
+	ifd0, _, err := t.readIFD(offset)
+	if err != nil {
+		return nil, err
+	}
+	t.values(ifd0, tags.IFD0)
+	if offset := t.pointer(ifd0, TagExifIFD); offset != 0 {
+		entries, _, err := t.readIFD(offset)
+		if err != nil {
+			return nil, err
+		}
+		t.values(entries, tags.Exif)
+	}
+	if offset := t.pointer(ifd0, TagGPSIFD); offset != 0 {
+		entries, _, err := t.readIFD(offset)
+		if err != nil {
+			return nil, err
+		}
+		t.values(entries, tags.GPS)
+	}
+	return tags, nil
+}
+
+// Return the offset stored in the entry for tag, or 0.
+func (t *tiffReader) pointer(entries []entry, tag uint16) uint32 {
+	for _, e := range entries {
+		if e.tag == tag {
+			return t.order.Uint32(e.raw[8:])
+		}
+	}
+	return 0
+}
+
+// Read the TIFF header. Return the byte order and the IFD0 offset.
+func readHeader(r io.ReaderAt) (order byteOrder, ifd0 uint32,
+	err error) {
+
+	var header [8]byte
+	if _, err = r.ReadAt(header[:], 0); err != nil {
+		return nil, 0, ErrNotTiff
+	}
+	switch string(header[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, 0, ErrNotTiff
+	}
+	if order.Uint16(header[2:]) != 42 {
+		return nil, 0, ErrNotTiff
+	}
+	return order, order.Uint32(header[4:]), nil
+}
+
+// An IFD entry to write: an existing raw entry kept verbatim, or a
+// value to lay out after the IFD.
+type item struct {
+	raw   []byte
+	typ   uint16
+	count uint32
+	data  []byte
+}
+
+func valueItem(order byteOrder, v Value) item {
+	return item{typ: v.Type, count: v.count(), data: v.encode(order)}
+}
+
+// Return the entries as items. The values in [start, end) are copied
+// to be laid out again, the others are kept where they are.
+func (t *tiffReader) keepItems(entries []entry, start,
+	end uint32) map[uint16]item {
+
+	items := make(map[uint16]item)
+	for _, e := range entries {
+		data, offset, ok := t.data(e)
+		if ok && offset != 0 && offset >= start && offset < end {
+			items[e.tag] = item{typ: t.order.Uint16(e.raw[2:]),
+				count: t.order.Uint32(e.raw[4:]), data: data}
+		} else {
+			items[e.tag] = item{raw: e.raw}
+		}
+	}
+	return items
+}
+
+// Return the end of the IFD at offset and of the values laid out
+// right after it: the space a new IFD can take in place of it. A maker
+// note ends it, as it can have offsets inside.
+func (t *tiffReader) footprint(offset uint32, entries []entry) uint32 {
+	type span struct{ offset, size uint32 }
+	var spans []span
+	for _, e := range entries {
+		if e.tag == TagMakerNote {
+			continue
+		}
+		if at, size, ok := t.extent(e); ok && at != 0 {
+			spans = append(spans, span{at, size})
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool {
+		return spans[i].offset < spans[j].offset
+	})
+	end := offset + 2 + uint32(12*len(entries)) + 4
+	for _, s := range spans {
+		if s.offset == end || s.offset == end+1 && end%2 != 0 {
+			end = s.offset + s.size
+		}
+	}
+	return end
+}
+
+// The IFDs being written from base, with their values after them.
+type ifdWriter struct {
+	order byteOrder
+	base  uint32
+	data  []byte
+}
+
+func (w *ifdWriter) align() {
+	if (w.base+uint32(len(w.data)))%2 != 0 {
+		w.data = append(w.data, 0)
+	}
+}
+
+// Append an IFD made of the items. Return its offset.
+func (w *ifdWriter) write(items map[uint16]item, next uint32) uint32 {
+	w.align()
+	tagIds := make([]int, 0, len(items))
+	for tag := range items {
+		tagIds = append(tagIds, int(tag))
+	}
+	sort.Ints(tagIds)
+
+	offset := w.base + uint32(len(w.data))
+	valuesOffset := offset + 2 + uint32(12*len(items)) + 4
+	var extra []byte
+	w.data = w.order.AppendUint16(w.data, uint16(len(items)))
+	for _, id := range tagIds {
+		tag := uint16(id)
+		it := items[tag]
+		if it.raw != nil {
+			w.data = append(w.data, it.raw...)
+			continue
+		}
+		raw := make([]byte, 12)
+		w.order.PutUint16(raw, tag)
+		w.order.PutUint16(raw[2:], it.typ)
+		w.order.PutUint32(raw[4:], it.count)
+		if len(it.data) <= 4 {
+			copy(raw[8:], it.data)
+		} else {
+			if len(extra)%2 != 0 {
+				extra = append(extra, 0)
+			}
+			w.order.PutUint32(raw[8:],
+				valuesOffset+uint32(len(extra)))
+			extra = append(extra, it.data...)
+		}
+		w.data = append(w.data, raw...)
+	}
+	w.data = w.order.AppendUint32(w.data, next)
+	w.data = append(w.data, extra...)
+	return offset
+}
+
+// Set the values in the items.
+func (w *ifdWriter) set(items map[uint16]item, values map[uint16]Value) {
+	for tag, value := range values {
+		items[tag] = valueItem(w.order, value)
+	}
+}
+
+// A write at an offset of the file.
+type patch struct {
+	offset int64
+	data   []byte
+}
+
+// Update the TIFF structure in r, of size bytes, with tags. An IFD is
+// rewritten in place if the new one fits, else appended. Return the
+// data to append at the end, the writes in place, and the new IFD0
+// offset to store in the header.
+func update(r io.ReaderAt, size int64, tags *Tags) (tail []byte,
+	patches []patch, ifd0 uint32, err error) {
+
+	order, oldIfd0, err := readHeader(r)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if size > math.MaxUint32 {
+		return nil, nil, 0, ErrTooLarge
+	}
+	t := &tiffReader{r, order}
+
+	var ifd0Entries []entry
+	var next uint32
+	if oldIfd0 != 0 {
+		ifd0Entries, next, err = t.readIFD(oldIfd0)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+	}
+
+	w := &ifdWriter{order: order, base: uint32(size)}
+	// Write the IFD at offset, or a new one if 0, with the values.
+	put := func(offset uint32, entries []entry, values map[uint16]Value,
+		next uint32) uint32 {
+
+		var end uint32
+		if offset != 0 {
+			end = min(t.footprint(offset, entries), uint32(size))
+		}
+		items := t.keepItems(entries, offset, end)
+		w.set(items, values)
+		if offset != 0 {
+			in := &ifdWriter{order: order, base: offset}
+			at := in.write(items, next)
+			if offset+uint32(len(in.data)) <= end {
+				patches = append(patches,
+					patch{int64(offset), in.data})
+				return at
+			}
+		}
+		return w.write(items, next)
+	}
+
+	ifd0Values := make(map[uint16]Value)
+	for tag, value := range tags.IFD0 {
+		ifd0Values[tag] = value
+	}
+
+	subIFDs := []struct {
+		tag    uint16
+		values map[uint16]Value
+	}{
+		{TagExifIFD, tags.Exif},
+		{TagGPSIFD, tags.GPS},
+	}
+	for _, sub := range subIFDs {
+		if len(sub.values) == 0 {
+			continue
+		}
+		var kept []entry
+		values := sub.values
+		offset := t.pointer(ifd0Entries, sub.tag)
+		if offset != 0 {
+			kept, _, err = t.readIFD(offset)
+			if err != nil {
+				return nil, nil, 0, err
+			}
+		} else if sub.tag == TagExifIFD {
+			values = make(map[uint16]Value)
+			for tag, value := range sub.values {
+				values[tag] = value
+			}
+			if _, ok := values[TagExifVersion]; !ok {
+				values[TagExifVersion] =
+					Undefined([]byte("0230"))
+			}
+		}
+		ifd0Values[sub.tag] = Long(put(offset, kept, values, 0))
+	}
+
+	ifd0 = put(oldIfd0, ifd0Entries, ifd0Values, next)
+	return w.data, patches, ifd0, nil
+}
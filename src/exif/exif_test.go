@@ -0,0 +1,362 @@
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Build a TIFF structure with an IFD0 with only the orientation.
+func orientedTiff() []byte {
+	order := binary.BigEndian
+	tiff := []byte{'M', 'M', 0, 42}
+	tiff = order.AppendUint32(tiff, 8)
+	tiff = order.AppendUint16(tiff, 1)
+	// Orientation, SHORT, 1, value 6
+	tiff = order.AppendUint16(tiff, 0x0112)
+	tiff = order.AppendUint16(tiff, TypeShort)
+	tiff = order.AppendUint32(tiff, 1)
+	tiff = append(tiff, 0, 6, 0, 0)
+	tiff = order.AppendUint32(tiff, 0)
+	return tiff
+}
+
+func segment(marker byte, payload []byte) []byte {
+	seg := []byte{0xff, marker}
+	seg = binary.BigEndian.AppendUint16(seg, uint16(len(payload)+2))
+	return append(seg, payload...)
+}
+
+func testTags() *Tags {
+	tags := NewTags()
+	tags.IFD0[TagMake] = Ascii("Canon")
+	tags.IFD0[TagModel] = Ascii("AE1 Program")
+	tags.Exif[TagFNumber] = Rational([2]uint32{7, 2})
+	tags.GPS[TagGPSVersionID] = Byte(2, 3, 0, 0)
+	tags.GPS[TagGPSLatitudeRef] = Ascii("N")
+	return tags
+}
+
+// Read back the raw entries of IFD0, Exif IFD and GPS IFD.
+func readBack(t *testing.T, tiff []byte) (ifd0, exif, gps []entry,
+	r *tiffReader) {
+
+	order, offset, err := readHeader(bytes.NewReader(tiff))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r = &tiffReader{bytes.NewReader(tiff), order}
+	if ifd0, _, err = r.readIFD(offset); err != nil {
+		t.Fatal(err)
+	}
+	if exif, _, err = r.readIFD(r.pointer(ifd0, TagExifIFD)); err != nil {
+		t.Fatal(err)
+	}
+	if gps, _, err = r.readIFD(r.pointer(ifd0, TagGPSIFD)); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func findEntry(entries []entry, tag uint16) []byte {
+	for _, e := range entries {
+		if e.tag == tag {
+			return e.raw
+		}
+	}
+	return nil
+}
+
+func checkTiff(t *testing.T, tiff []byte) {
+	ifd0, exif, gps, r := readBack(t, tiff)
+
+	orientation := orientedTiff()[10:22]
+	if raw := findEntry(ifd0, 0x0112); !bytes.Equal(raw, orientation) {
+		t.Errorf("Orientation is % x, expected % x", raw, orientation)
+	}
+	raw := findEntry(ifd0, TagModel)
+	if raw == nil {
+		t.Fatal("No Model")
+	}
+	offset := r.order.Uint32(raw[8:])
+	if model := string(tiff[offset : offset+11]); model != "AE1 Program" {
+		t.Errorf("Model is %q", model)
+	}
+	raw = findEntry(exif, TagFNumber)
+	if raw == nil {
+		t.Fatal("No FNumber")
+	}
+	offset = r.order.Uint32(raw[8:])
+	num := r.order.Uint32(tiff[offset:])
+	den := r.order.Uint32(tiff[offset+4:])
+	if num != 7 || den != 2 {
+		t.Errorf("FNumber is %d/%d", num, den)
+	}
+	if findEntry(exif, TagExifVersion) == nil {
+		t.Error("No ExifVersion")
+	}
+	if raw = findEntry(gps, TagGPSVersionID); raw == nil ||
+		!bytes.Equal(raw[8:], []byte{2, 3, 0, 0}) {
+		t.Errorf("GPSVersionID is % x", raw)
+	}
+}
+
+func TestUpdateJpeg(t *testing.T) {
+	jfif := segment(markerAPP0, []byte("JFIF\x00\x01\x02\x00\x00\x01\x00\x01\x00\x00"))
+	icc := segment(0xe2, []byte("ICC_PROFILE\x00\x01\x01profile"))
+	app1 := segment(markerAPP1, append([]byte("Exif\x00\x00"),
+		orientedTiff()...))
+	scan := []byte{0xff, markerSOS, 0, 2, 1, 2, 3, 0xff, 0xd9}
+
+	var jpeg []byte
+	jpeg = append(jpeg, 0xff, markerSOI)
+	jpeg = append(jpeg, jfif...)
+	jpeg = append(jpeg, app1...)
+	jpeg = append(jpeg, icc...)
+	jpeg = append(jpeg, scan...)
+
+	updated, err := UpdateJpeg(jpeg, testTags())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(updated, jpeg[:2+len(jfif)]) {
+		t.Error("JFIF segment changed")
+	}
+	if !bytes.HasSuffix(updated, append(icc, scan...)) {
+		t.Error("ICC profile or image data changed")
+	}
+
+	start, end, _, err := findExifSegment(updated)
+	if err != nil || start < 0 {
+		t.Fatalf("EXIF segment not found: %v", err)
+	}
+	checkTiff(t, updated[start+4+len(exifHeader):end])
+
+	// Without EXIF, the segment is created after JFIF.
+	var bare []byte
+	bare = append(bare, 0xff, markerSOI)
+	bare = append(bare, jfif...)
+	bare = append(bare, scan...)
+	updated, err = UpdateJpeg(bare, testTags())
+	if err != nil {
+		t.Fatal(err)
+	}
+	start, _, _, err = findExifSegment(updated)
+	if err != nil || start != 2+len(jfif) {
+		t.Errorf("EXIF segment at %d: %v", start, err)
+	}
+}
+
+func TestRead(t *testing.T) {
+	original := orientedTiff()
+	tail, _, ifd0, err := update(bytes.NewReader(original),
+		int64(len(original)), testTags())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tiff := append(original, tail...)
+	binary.BigEndian.PutUint32(tiff[4:], ifd0)
+
+	tags, err := read(bytes.NewReader(tiff))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if model := tags.IFD0[TagModel].Text; model != "AE1 Program" {
+		t.Errorf("Model is %q", model)
+	}
+	if o := tags.IFD0[0x0112].Ints; len(o) != 1 || o[0] != 6 {
+		t.Errorf("Orientation is %v", o)
+	}
+	if r := tags.Exif[TagFNumber].Rationals; len(r) != 1 ||
+		r[0] != [2]int64{7, 2} {
+		t.Errorf("FNumber is %v", r)
+	}
+}
+
+func TestWriteTiff(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scan.tif")
+	original := orientedTiff()
+	// Some image data.
+	original = append(original, 1, 2, 3)
+	if err := os.WriteFile(path, original, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteFile(path, testTags()); err != nil {
+		t.Fatal(err)
+	}
+	tiff, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tiff[8:len(original)], original[8:]) {
+		t.Error("Original data changed")
+	}
+	checkTiff(t, tiff)
+}
+
+// Write the tags twice into the file at path, and check it doesn't
+// grow the second time.
+func writeTwice(t *testing.T, path string) []byte {
+	if err := WriteFile(path, testTags()); err != nil {
+		t.Fatal(err)
+	}
+	first, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags := testTags()
+	tags.IFD0[TagModel] = Ascii("AE1 Program") // Same size
+	if err := WriteFile(path, tags); err != nil {
+		t.Fatal(err)
+	}
+	second, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(second) != len(first) {
+		t.Errorf("Size is %d, then %d", len(first), len(second))
+	}
+	return second
+}
+
+func TestWriteTwice(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "scan.tif")
+	original := append(orientedTiff(), 1, 2, 3)
+	if err := os.WriteFile(path, original, 0644); err != nil {
+		t.Fatal(err)
+	}
+	checkTiff(t, writeTwice(t, path))
+
+	path = filepath.Join(dir, "scan.jpg")
+	var jpeg []byte
+	jpeg = append(jpeg, 0xff, markerSOI)
+	jpeg = append(jpeg, segment(markerAPP1, append([]byte("Exif\x00\x00"),
+		orientedTiff()...))...)
+	jpeg = append(jpeg, 0xff, markerSOS, 0, 2, 1, 2, 3, 0xff, 0xd9)
+	if err := os.WriteFile(path, jpeg, 0644); err != nil {
+		t.Fatal(err)
+	}
+	updated := writeTwice(t, path)
+	start, end, _, err := findExifSegment(updated)
+	if err != nil || start < 0 {
+		t.Fatalf("EXIF segment not found: %v", err)
+	}
+	checkTiff(t, updated[start+4+len(exifHeader):end])
+
+	path = filepath.Join(dir, "scan.png")
+	png := []byte("\x89PNG\r\n\x1a\n")
+	if err := os.WriteFile(path, png, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFile(path, testTags()); err != ErrUnsupported {
+		t.Errorf("Writing a PNG: %v", err)
+	}
+}
+
+// Build a TIFF structure with, in IFD0, an entry of unknown type and
+// a SubIFDs pointer, an Exif IFD with a maker note right after it, and
+// an IFD1 with a JPEG thumbnail. Return it with the ranges that must
+// stay as they are.
+func richTiff() (tiff []byte, kept [][2]int) {
+	order := binary.BigEndian
+	entry := func(tag, typ uint16, count uint32, value uint32) {
+		tiff = order.AppendUint16(tiff, tag)
+		tiff = order.AppendUint16(tiff, typ)
+		tiff = order.AppendUint32(tiff, count)
+		tiff = order.AppendUint32(tiff, value)
+	}
+	keep := func(start int) {
+		kept = append(kept, [2]int{start, len(tiff)})
+	}
+
+	tiff = []byte{'M', 'M', 0, 42}
+	tiff = order.AppendUint32(tiff, 8)
+	// IFD0 at 8, 4 entries: 8 + 2 + 48 + 4 = 62.
+	tiff = order.AppendUint16(tiff, 4)
+	entry(0x0112, TypeShort, 1, 6<<16)
+	entry(0x014a, TypeLong, 1, 62)       // SubIFDs
+	entry(0xc000, 99, 8, 80)             // Unknown type
+	entry(TagExifIFD, TypeLong, 1, 88)   // Exif IFD
+	tiff = order.AppendUint32(tiff, 130) // IFD1
+	// The sub IFD at 62, 1 entry: 62 + 18 = 80.
+	start := len(tiff)
+	tiff = order.AppendUint16(tiff, 1)
+	entry(0x00fe, TypeLong, 1, 1)
+	tiff = order.AppendUint32(tiff, 0)
+	// The unknown value at 80.
+	tiff = append(tiff, "unknown!"...)
+	keep(start)
+	// The Exif IFD at 88, 2 entries: 88 + 30 = 118.
+	tiff = order.AppendUint16(tiff, 2)
+	entry(TagExifVersion, TypeUndefined, 4, 0x30323330)
+	start = len(tiff)
+	entry(TagMakerNote, TypeUndefined, 12, 118)
+	keep(start)
+	tiff = order.AppendUint32(tiff, 0)
+	// The maker note at 118, with an offset inside.
+	start = len(tiff)
+	tiff = append(tiff, "Nikon\x00"...)
+	tiff = order.AppendUint32(tiff, 118)
+	tiff = append(tiff, 0, 0)
+	keep(start)
+	// IFD1 at 130, 2 entries: 130 + 30 = 160.
+	start = len(tiff)
+	tiff = order.AppendUint16(tiff, 2)
+	entry(TagJPEGInterchangeFormat, TypeLong, 1, 160)
+	entry(TagJPEGInterchangeFormatLength, TypeLong, 1, 7)
+	tiff = order.AppendUint32(tiff, 0)
+	tiff = append(tiff, 0xff, markerSOI, 1, 2, 3, 0xff, 0xd9)
+	keep(start)
+	return
+}
+
+// What isn't understood is kept byte for byte, where it is.
+func TestUpdateKeeps(t *testing.T) {
+	tiff, kept := richTiff()
+	if len(tiff) != 167 {
+		t.Fatalf("TIFF of %d bytes", len(tiff))
+	}
+	jpeg := []byte{0xff, markerSOI}
+	jpeg = append(jpeg, segment(markerAPP1, append([]byte("Exif\x00\x00"),
+		tiff...))...)
+	jpeg = append(jpeg, 0xff, markerSOS, 0, 2, 1, 2, 3, 0xff, 0xd9)
+
+	for pass := 0; pass < 2; pass++ {
+		var err error
+		if jpeg, err = UpdateJpeg(jpeg, testTags()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start, end, _, err := findExifSegment(jpeg)
+	if err != nil || start < 0 {
+		t.Fatalf("EXIF segment not found: %v", err)
+	}
+	updated := jpeg[start+4+len(exifHeader) : end]
+	checkTiff(t, updated)
+	for _, k := range kept {
+		if !bytes.Equal(updated[k[0]:k[1]], tiff[k[0]:k[1]]) {
+			t.Errorf("Bytes %d to %d are % x, expected % x", k[0], k[1],
+				updated[k[0]:k[1]], tiff[k[0]:k[1]])
+		}
+	}
+
+	ifd0, _, _, r := readBack(t, updated)
+	// IFD0 is rewritten with its unknown entries as they were.
+	for tag, raw := range map[uint16][]byte{
+		0x014a: tiff[22:34],
+		0xc000: tiff[34:46],
+	} {
+		if updated := findEntry(ifd0, tag); !bytes.Equal(updated, raw) {
+			t.Errorf("Entry %x is % x, expected % x", tag, updated, raw)
+		}
+	}
+	if _, next, err := r.readIFD(r.order.Uint32(updated[4:])); err != nil ||
+		next != 130 {
+		t.Errorf("IFD1 at %d: %v", next, err)
+	}
+}
@@ -0,0 +1,214 @@
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+)
+
+const (
+	markerSOI  = 0xd8
+	markerSOS  = 0xda
+	markerAPP0 = 0xe0
+	markerAPP1 = 0xe1
+)
+
+var exifHeader = []byte("Exif\x00\x00")
+
+// Read the tags of the JPEG or TIFF file at path. A JPEG without EXIF
+// has no tags.
+func ReadFile(path string) (*Tags, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 2 || data[0] != 0xff || data[1] != markerSOI {
+		return read(bytes.NewReader(data))
+	}
+
+	start, end, _, err := findExifSegment(data)
+	if err != nil {
+		return nil, err
+	}
+	if start < 0 {
+		return NewTags(), nil
+	}
+	return read(bytes.NewReader(data[start+4+len(exifHeader) : end]))
+}
+
+// Write the tags into the JPEG or TIFF file at path. Other files are
+// left untouched, with ErrUnsupported.
+func WriteFile(path string, tags *Tags) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	var magic [4]byte
+	_, err = io.ReadFull(f, magic[:])
+	f.Close()
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrUnsupported
+	} else if err != nil {
+		return err
+	}
+
+	switch string(magic[:]) {
+	case "II*\x00", "MM\x00*":
+		return WriteTiff(path, tags)
+	}
+	if magic[0] == 0xff && magic[1] == markerSOI {
+		return WriteJpeg(path, tags)
+	}
+	return ErrUnsupported
+}
+
+// Write the tags into the TIFF file at path. The file is updated
+// in place: writing the same tags again doesn't change its size.
+func WriteTiff(path string, tags *Tags) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	order, _, err := readHeader(f)
+	if err != nil {
+		return err
+	}
+	tail, patches, ifd0, err := update(f, info.Size(), tags)
+	if err != nil {
+		return err
+	}
+	if _, err = f.WriteAt(tail, info.Size()); err != nil {
+		return err
+	}
+	// The new IFDs the others point to are written first.
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	for _, p := range patches {
+		if _, err = f.WriteAt(p.data, p.offset); err != nil {
+			return err
+		}
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	_, err = f.WriteAt(order.AppendUint32(nil, ifd0), 4)
+	return err
+}
+
+// Write the tags into the JPEG file at path.
+func WriteJpeg(path string, tags *Tags) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	data, err = UpdateJpeg(data, tags)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, info.Mode()); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Find the EXIF APP1 segment. Return the start and end of the
+// segment, and the insertion point if there is none.
+func findExifSegment(data []byte) (start int, end int, insert int,
+	err error) {
+
+	if len(data) < 2 || data[0] != 0xff || data[1] != markerSOI {
+		return 0, 0, 0, ErrNotJpeg
+	}
+	insert = 2
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xff {
+			return 0, 0, 0, ErrNotJpeg
+		}
+		marker := data[pos+1]
+		if marker == markerSOS {
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		segEnd := pos + 2 + length
+		if length < 2 || segEnd > len(data) {
+			return 0, 0, 0, ErrNotJpeg
+		}
+		payload := data[pos+4 : segEnd]
+		if marker == markerAPP1 && bytes.HasPrefix(payload, exifHeader) {
+			return pos, segEnd, 0, nil
+		}
+		// EXIF goes after JFIF.
+		if marker == markerAPP0 && pos == insert {
+			insert = segEnd
+		}
+		pos = segEnd
+	}
+	return -1, -1, insert, nil
+}
+
+// Update the EXIF of the JPEG data with tags. Return the new JPEG
+// data. The TIFF structure of the EXIF segment is updated as a TIFF
+// file, see WriteTiff. Every other segment is left untouched.
+func UpdateJpeg(data []byte, tags *Tags) ([]byte, error) {
+	start, end, insert, err := findExifSegment(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var tiff []byte
+	if start >= 0 {
+		tiff = data[start+4+len(exifHeader) : end]
+	} else {
+		// An empty TIFF structure, without IFD0.
+		tiff = []byte{'I', 'I', 42, 0, 0, 0, 0, 0}
+		start = insert
+		end = insert
+	}
+
+	r := bytes.NewReader(tiff)
+	order, _, err := readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	tail, patches, ifd0, err := update(r, int64(len(tiff)), tags)
+	if err != nil {
+		return nil, err
+	}
+
+	length := 2 + len(exifHeader) + len(tiff) + len(tail)
+	if length > 0xffff {
+		return nil, ErrTooLarge
+	}
+	segment := make([]byte, 0, 2+length)
+	segment = append(segment, 0xff, markerAPP1)
+	segment = binary.BigEndian.AppendUint16(segment, uint16(length))
+	segment = append(segment, exifHeader...)
+	tiffStart := len(segment)
+	segment = append(segment, tiff...)
+	segment = append(segment, tail...)
+	for _, p := range patches {
+		copy(segment[tiffStart+int(p.offset):], p.data)
+	}
+	order.PutUint32(segment[tiffStart+4:], ifd0)
+
+	result := make([]byte, 0, len(data)-(end-start)+len(segment))
+	result = append(result, data[:start]...)
+	result = append(result, segment...)
+	result = append(result, data[end:]...)
+	return result, nil
+}
@@ -0,0 +1,230 @@
+// Offline reverse geocoding from GeoNames dumps.
+//
+// See LICENSE
+
+package geonames
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gitlab.com/photo/e4f-go/src/e4f"
+)
+
+// A GeoNames feature.
+type Place struct {
+	Id        int
+	Name      string
+	Lat, Long float64
+	// Feature class, 'P' for the populated places.
+	Class       byte
+	CountryCode string
+	Admin1Code  string
+	// Resolved from the admin1 codes and country info, if loaded.
+	State   string
+	Country string
+}
+
+// Size of the cells of the index, in degrees.
+const cellSize = 1.0
+
+type cell struct {
+	lat, long int
+}
+
+// A grid of the places, for the nearest neighbour searches.
+type Index struct {
+	cells map[cell][]*Place
+	count int
+}
+
+// Number of cells around the earth.
+const longCells = int(360 / cellSize)
+
+// Return the cell at the longitude index long, wrapped around the
+// antimeridian.
+func wrapCell(lat, long int) cell {
+	long = ((long+longCells/2)%longCells+longCells)%longCells -
+		longCells/2
+	return cell{lat, long}
+}
+
+func cellFor(lat, long float64) cell {
+	return wrapCell(int(math.Floor(lat/cellSize)),
+		int(math.Floor(long/cellSize)))
+}
+
+func NewIndex() *Index {
+	return &Index{cells: make(map[cell][]*Place)}
+}
+
+// Number of places in the index.
+func (idx *Index) Len() int {
+	return idx.count
+}
+
+func (idx *Index) Add(place *Place) {
+	c := cellFor(place.Lat, place.Long)
+	idx.cells[c] = append(idx.cells[c], place)
+	idx.count++
+}
+
+// Return the place nearest to the coordinates accepted by filter, if
+// any, and its distance in meters. filter may be nil. Only searches
+// up to maxRings cells around, across the antimeridian too.
+func (idx *Index) Nearest(lat, long float64, filter func(*Place) bool,
+	maxRings int) (nearest *Place, dist float64) {
+
+	center := cellFor(lat, long)
+	// A cell is at least this wide, in meters, away from the poles.
+	cellMeters := cellSize * 111000 * math.Max(0.1,
+		math.Cos(math.Min(89, math.Abs(lat))*math.Pi/180))
+	for ring := 0; ring <= maxRings; ring++ {
+		// Anything further out is at least this far.
+		if nearest != nil && dist < float64(ring-1)*cellMeters {
+			break
+		}
+		for dlat := -ring; dlat <= ring; dlat++ {
+			for dlong := -ring; dlong <= ring; dlong++ {
+				if max(abs(dlat), abs(dlong)) != ring {
+					continue
+				}
+				c := wrapCell(center.lat+dlat, center.long+dlong)
+				for _, place := range idx.cells[c] {
+					if filter != nil && !filter(place) {
+						continue
+					}
+					d := e4f.Distance(lat, long, place.Lat,
+						place.Long)
+					if nearest == nil || d < dist ||
+						(d == dist && place.Id < nearest.Id) {
+						nearest, dist = place, d
+					}
+				}
+			}
+		}
+	}
+	return
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
+
+// Read a GeoNames dump, like cities1000.txt or allCountries.txt, into
+// the index.
+func (idx *Index) Read(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 11 {
+			continue
+		}
+		place := &Place{
+			Name:        fields[1],
+			CountryCode: fields[8],
+			Admin1Code:  fields[10],
+		}
+		var err error
+		place.Id, err = strconv.Atoi(fields[0])
+		if err == nil {
+			place.Lat, err = strconv.ParseFloat(fields[4], 64)
+		}
+		if err == nil {
+			place.Long, err = strconv.ParseFloat(fields[5], 64)
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if fields[6] != "" {
+			place.Class = fields[6][0]
+		}
+		idx.Add(place)
+	}
+	return scanner.Err()
+}
+
+// Read a "code<TAB>name..." file into names.
+func readNames(r io.Reader, codeField, nameField int,
+	names map[string]string) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		text := scanner.Text()
+		if strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, "\t")
+		if len(fields) <= codeField || len(fields) <= nameField {
+			continue
+		}
+		names[fields[codeField]] = fields[nameField]
+	}
+	return scanner.Err()
+}
+
+// Resolve the state and country names of the places, from the
+// admin1CodesASCII.txt and countryInfo.txt readers. Either can be
+// nil.
+func (idx *Index) ResolveNames(admin1, countries io.Reader) error {
+	states := make(map[string]string)
+	countryNames := make(map[string]string)
+	if admin1 != nil {
+		if err := readNames(admin1, 0, 1, states); err != nil {
+			return err
+		}
+	}
+	if countries != nil {
+		if err := readNames(countries, 0, 4, countryNames); err != nil {
+			return err
+		}
+	}
+	for _, places := range idx.cells {
+		for _, place := range places {
+			place.State = states[place.CountryCode+"."+place.Admin1Code]
+			place.Country = countryNames[place.CountryCode]
+		}
+	}
+	return nil
+}
+
+// Load the dump at path. admin1CodesASCII.txt and countryInfo.txt
+// are loaded from the same directory if present.
+func Load(path string) (*Index, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	idx := NewIndex()
+	if err = idx.Read(file); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	var readers []io.Reader
+	for _, name := range []string{"admin1CodesASCII.txt", "countryInfo.txt"} {
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			readers = append(readers, nil)
+			continue
+		}
+		defer f.Close()
+		readers = append(readers, f)
+	}
+	if err = idx.ResolveNames(readers[0], readers[1]); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
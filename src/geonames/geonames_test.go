@@ -0,0 +1,56 @@
+package geonames
+
+import (
+	"strings"
+	"testing"
+)
+
+const dump = "6077243\tMontreal\tMontreal\t\t45.50884\t-73.58781\tP\tPPLA2\tCA\t\t10\t06\t\t\t1600000\t\t216\tAmerica/Toronto\t2019-08-28\n" +
+	"6173331\tVancouver\tVancouver\t\t49.24966\t-123.11934\tP\tPPLA2\tCA\t\t02\t\t\t\t600000\t\t70\tAmerica/Vancouver\t2019-01-09\n" +
+	"2198148\tLevuka\tLevuka\t\t-17.68\t178.83\tP\tPPLA\tFJ\t\t01\t\t\t\t8000\t\t5\tPacific/Fiji\t2019-01-01\n" +
+	"6077265\tMont Royal\tMont Royal\t\t45.5058\t-73.5878\tT\tMT\tCA\t\t10\t\t\t\t0\t\t233\tAmerica/Toronto\t2019-01-01\n"
+
+func TestNearest(t *testing.T) {
+	idx := NewIndex()
+	if err := idx.Read(strings.NewReader(dump)); err != nil {
+		t.Fatal(err)
+	}
+	err := idx.ResolveNames(strings.NewReader("CA.10\tQuebec\tQuebec\t6115047\n"),
+		strings.NewReader("# comment\nCA\tCAN\t124\tCA\tCanada\tOttawa\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := idx.Len(); l != 4 {
+		t.Errorf("Found %d places, expected 4", l)
+	}
+
+	populated := func(p *Place) bool { return p.Class == 'P' }
+	place, _ := idx.Nearest(45.4976, -73.6321, populated, 2)
+	if place == nil || place.Name != "Montreal" {
+		t.Fatalf("Found %v, expected Montreal", place)
+	}
+	if place.State != "Quebec" || place.Country != "Canada" {
+		t.Errorf("Found %s, %s", place.State, place.Country)
+	}
+
+	place, _ = idx.Nearest(45.5, -73.59, nil, 2)
+	if place == nil || place.Name != "Mont Royal" {
+		t.Errorf("Found %v, expected Mont Royal", place)
+	}
+
+	// Vancouver is too far.
+	place, _ = idx.Nearest(49.3, -120, populated, 2)
+	if place != nil {
+		t.Errorf("Found %v, expected nothing", place)
+	}
+	place, _ = idx.Nearest(49.3, -120, populated, 5)
+	if place == nil || place.Name != "Vancouver" {
+		t.Errorf("Found %v, expected Vancouver", place)
+	}
+
+	// Across the antimeridian.
+	place, dist := idx.Nearest(-17.7, -179.9, populated, 2)
+	if place == nil || place.Name != "Levuka" || dist > 200000 {
+		t.Errorf("Found %v at %f m, expected Levuka", place, dist)
+	}
+}
@@ -0,0 +1,75 @@
+// Read and write GPX 1.1 files.
+//
+// See LICENSE
+
+package gpx
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+const Namespace = "http://www.topografix.com/GPX/1/1"
+
+// The GPX document. Reading also accepts GPX 1.0.
+type Gpx struct {
+	// Set by New. Not tagged to read any namespace.
+	XMLName   xml.Name
+	Version   string     `xml:"version,attr"`
+	Creator   string     `xml:"creator,attr"`
+	Waypoints []Waypoint `xml:"wpt"`
+	Tracks    []Track    `xml:"trk"`
+}
+
+// A waypoint, also used for the track points.
+type Waypoint struct {
+	Lat  float64    `xml:"lat,attr"`
+	Lon  float64    `xml:"lon,attr"`
+	Ele  *float64   `xml:"ele,omitempty"`
+	Time *time.Time `xml:"time,omitempty"`
+	Name string     `xml:"name,omitempty"`
+	Desc string     `xml:"desc,omitempty"`
+	Type string     `xml:"type,omitempty"`
+}
+
+type Track struct {
+	Name     string    `xml:"name,omitempty"`
+	Desc     string    `xml:"desc,omitempty"`
+	Segments []Segment `xml:"trkseg"`
+}
+
+type Segment struct {
+	Points []Waypoint `xml:"trkpt"`
+}
+
+func New(creator string) *Gpx {
+	return &Gpx{
+		XMLName: xml.Name{Space: Namespace, Local: "gpx"},
+		Version: "1.1",
+		Creator: creator,
+	}
+}
+
+// Write the GPX document.
+func (g *Gpx) Write(w io.Writer) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(g); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// Read a GPX document.
+func Read(r io.Reader) (*Gpx, error) {
+	g := &Gpx{}
+	if err := xml.NewDecoder(r).Decode(g); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
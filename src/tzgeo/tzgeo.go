@@ -0,0 +1,158 @@
+// Find the time zone of a location from the time zone boundaries,
+// like the GeoJSON of timezone-boundary-builder.
+//
+// See LICENSE
+
+package tzgeo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// A ring of [long, lat] points.
+type ring [][2]float64
+
+// A polygon is the outer ring then the holes.
+type polygon []ring
+
+type bbox struct {
+	minLong, minLat, maxLong, maxLat float64
+}
+
+func (b *bbox) contains(lat, long float64) bool {
+	return long >= b.minLong && long <= b.maxLong &&
+		lat >= b.minLat && lat <= b.maxLat
+}
+
+type zone struct {
+	tzid     string
+	bounds   bbox
+	polygons []polygon
+}
+
+// The time zones boundaries.
+type Boundaries struct {
+	zones []zone
+}
+
+type geometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+type feature struct {
+	Properties struct {
+		Tzid string `json:"tzid"`
+	} `json:"properties"`
+	Geometry geometry `json:"geometry"`
+}
+
+type featureCollection struct {
+	Features []feature `json:"features"`
+}
+
+// Read the boundaries from a GeoJSON FeatureCollection of Polygon or
+// MultiPolygon with a "tzid" property.
+func Read(r io.Reader) (*Boundaries, error) {
+	var collection featureCollection
+	if err := json.NewDecoder(r).Decode(&collection); err != nil {
+		return nil, err
+	}
+
+	b := &Boundaries{}
+	for _, f := range collection.Features {
+		z := zone{tzid: f.Properties.Tzid}
+		switch f.Geometry.Type {
+		case "Polygon":
+			var p polygon
+			if err := json.Unmarshal(f.Geometry.Coordinates, &p); err != nil {
+				return nil, fmt.Errorf("%s: %w", z.tzid, err)
+			}
+			z.polygons = []polygon{p}
+		case "MultiPolygon":
+			if err := json.Unmarshal(f.Geometry.Coordinates,
+				&z.polygons); err != nil {
+				return nil, fmt.Errorf("%s: %w", z.tzid, err)
+			}
+		default:
+			continue
+		}
+		if z.tzid == "" || len(z.polygons) == 0 {
+			continue
+		}
+		z.bounds = bbox{180, 90, -180, -90}
+		for _, p := range z.polygons {
+			if len(p) == 0 {
+				continue
+			}
+			for _, pt := range p[0] {
+				z.bounds.minLong = min(z.bounds.minLong, pt[0])
+				z.bounds.maxLong = max(z.bounds.maxLong, pt[0])
+				z.bounds.minLat = min(z.bounds.minLat, pt[1])
+				z.bounds.maxLat = max(z.bounds.maxLat, pt[1])
+			}
+		}
+		b.zones = append(b.zones, z)
+	}
+	return b, nil
+}
+
+// Load the boundaries from the GeoJSON file at path.
+func Load(path string) (*Boundaries, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	b, err := Read(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return b, nil
+}
+
+// Ray casting.
+func (r ring) contains(lat, long float64) bool {
+	inside := false
+	for i, j := 0, len(r)-1; i < len(r); j, i = i, i+1 {
+		xi, yi := r[i][0], r[i][1]
+		xj, yj := r[j][0], r[j][1]
+		if (yi > lat) != (yj > lat) &&
+			long < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+func (p polygon) contains(lat, long float64) bool {
+	if len(p) == 0 || !p[0].contains(lat, long) {
+		return false
+	}
+	for _, hole := range p[1:] {
+		if hole.contains(lat, long) {
+			return false
+		}
+	}
+	return true
+}
+
+// Return the IANA time zone name at the location. ok is false if
+// outside all the zones.
+func (b *Boundaries) Lookup(lat, long float64) (tzid string, ok bool) {
+	for i := range b.zones {
+		z := &b.zones[i]
+		if !z.bounds.contains(lat, long) {
+			continue
+		}
+		for _, p := range z.polygons {
+			if p.contains(lat, long) {
+				return z.tzid, true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,43 @@
+package tzgeo
+
+import (
+	"strings"
+	"testing"
+)
+
+const boundaries = `{"type": "FeatureCollection", "features": [
+{"type": "Feature", "properties": {"tzid": "America/Toronto"},
+ "geometry": {"type": "Polygon", "coordinates": [
+  [[-80, 40], [-70, 40], [-70, 50], [-80, 50], [-80, 40]],
+  [[-76, 44], [-74, 44], [-74, 46], [-76, 46], [-76, 44]]]}},
+{"type": "Feature", "properties": {"tzid": "Europe/Paris"},
+ "geometry": {"type": "MultiPolygon", "coordinates": [
+  [[[0, 42], [8, 42], [8, 51], [0, 51], [0, 42]]],
+  [[[8.5, 41.3], [9.6, 41.3], [9.6, 43.1], [8.5, 43.1], [8.5, 41.3]]]]}}
+]}`
+
+func TestLookup(t *testing.T) {
+	b, err := Read(strings.NewReader(boundaries))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		lat, long float64
+		tzid      string
+	}{
+		{45.5, -73.6, "America/Toronto"},
+		// In the hole.
+		{45, -75, ""},
+		{48.85, 2.35, "Europe/Paris"},
+		{42.0, 9.0, "Europe/Paris"},
+		{0, 0, ""},
+	}
+	for _, test := range tests {
+		tzid, ok := b.Lookup(test.lat, test.long)
+		if tzid != test.tzid || ok != (test.tzid != "") {
+			t.Errorf("%v,%v: found %q, expected %q", test.lat,
+				test.long, tzid, test.tzid)
+		}
+	}
+}
@@ -4,10 +4,15 @@ package xmp
 // #include <exempi/xmp.h>
 // #include <exempi/xmpconsts.h>
 import "C"
-import "unsafe"
+import (
+	"strings"
+	"time"
+	"unsafe"
+)
 
 var (
 	NS_EXIF = (*C.char)(unsafe.Pointer(&C.NS_EXIF))
+	NS_EXIF_EX = (*C.char)(unsafe.Pointer(&C.NS_EXIF_EX))
 	NS_TIFF = (*C.char)(unsafe.Pointer(&C.NS_TIFF))
 	NS_XAP = (*C.char)(unsafe.Pointer(&C.NS_XAP))
 	NS_XAP_RIGHTS = (*C.char)(unsafe.Pointer(&C.NS_XAP_RIGHTS))
@@ -23,9 +28,12 @@ var (
 	NS_DIMENSIONS_TYPE = (*C.char)(unsafe.Pointer(&C.NS_DIMENSIONS_TYPE))
 	NS_CC = (*C.char)(unsafe.Pointer(&C.NS_CC))
 	NS_PDF = (*C.char)(unsafe.Pointer(&C.NS_PDF))
+	NS_XML = (*C.char)(unsafe.Pointer(&C.NS_XML))
 
 	// see http://analogexif.sourceforge.net/help/analogexif-xmp.php
 	NS_ANALOG = C.CString("http://analogexif.sourceforge.net/ns")
+	// e4f-go own properties
+	NS_E4F = C.CString("http://gitlab.com/photo/e4f-go/ns/1.0/")
 )
 
 
@@ -35,6 +43,27 @@ const (
 	PROP_ARRAY_IS_UNORDERED = PROP_VALUE_IS_ARRAY
 	PROP_ARRAY_IS_ORDERED = 0x00000400
 	PROP_ARRAY_IS_ALT    = 0x00000800
+	PROP_ARRAY_IS_ALTTEXT = 0x00001000
+)
+
+const (
+	OPEN_NOOPTION  = 0x00000000
+	OPEN_READ      = 0x00000001
+	OPEN_FORUPDATE = 0x00000002
+	OPEN_ONLYXMP   = 0x00000004
+)
+
+const (
+	CLOSE_NOOPTION   = 0x0000
+	CLOSE_SAFEUPDATE = 0x0001
+)
+
+const (
+	ITER_PROPERTIES     = 0x0000
+	ITER_JUSTCHILDREN   = 0x0100
+	ITER_JUSTLEAFNODES  = 0x0200
+	ITER_JUSTLEAFNAME   = 0x0400
+	ITER_OMITQUALIFIERS = 0x1000
 )
 
 const (
@@ -49,6 +78,11 @@ const (
 
 type Xmp C.XmpPtr
 type String C.XmpStringPtr
+type Iterator C.XmpIteratorPtr
+type File C.XmpFilePtr
+
+// A namespace URI as passed to the property functions.
+type Namespace = *C.char
 
 func RegisterNamespace(uri *C.char, prefix string, s String) bool {
 	prefixC := C.CString(prefix)
@@ -56,10 +90,61 @@ func RegisterNamespace(uri *C.char, prefix string, s String) bool {
 	return bool(C.xmp_register_namespace(uri, prefixC, s))
 }
 
+// Return the namespace for uri, to register it. It is never freed.
+func NewNamespace(uri string) Namespace {
+	return C.CString(uri)
+}
+
+// The namespaces looked up by prefix.
+var prefixNamespaces = make(map[string]Namespace)
+
+// Register the namespace uri with prefix, for PrefixNamespace. Return
+// false if the namespace was already registered with another prefix.
+func RegisterPrefix(prefix string, uri string) bool {
+	ns := NewNamespace(uri)
+	registered := StringNew()
+	defer StringFree(registered)
+	if !RegisterNamespace(ns, prefix, registered) {
+		return false
+	}
+	if strings.TrimSuffix(StringGo(registered), ":") != prefix {
+		return false
+	}
+	prefixNamespaces[prefix] = ns
+	return true
+}
+
+// Return the namespace registered for prefix, like "dc".
+func PrefixNamespace(prefix string) (Namespace, bool) {
+	if ns, found := prefixNamespaces[prefix]; found {
+		return ns, true
+	}
+	prefixC := C.CString(prefix)
+	defer C.free(unsafe.Pointer(prefixC))
+	uri := StringNew()
+	defer StringFree(uri)
+	if !C.xmp_prefix_namespace_uri(prefixC, uri) {
+		return nil, false
+	}
+	ns := NewNamespace(StringGo(uri))
+	prefixNamespaces[prefix] = ns
+	return ns, true
+}
+
 func NewEmpty() Xmp {
 	return Xmp(C.xmp_new_empty())
 }
 
+// Create a new Xmp by parsing buffer. Return nil on failure.
+func New(buffer []byte) Xmp {
+	if len(buffer) == 0 {
+		return nil
+	}
+	bufferC := C.CBytes(buffer)
+	defer C.free(bufferC)
+	return Xmp(C.xmp_new((*C.char)(bufferC), C.size_t(len(buffer))))
+}
+
 func Free(x Xmp) {
 	C.xmp_free(x)
 }
@@ -76,6 +161,20 @@ func Serialize(x Xmp, buffer String, options C.uint32_t,
 	return bool(ret)
 }
 
+// Get the value of the property. ok is false if it doesn't exist.
+func GetProperty(x Xmp, schema *C.char, name string) (value string, ok bool) {
+
+	nameC := C.CString(name)
+	defer C.free(unsafe.Pointer(nameC))
+	property := StringNew()
+	defer StringFree(property)
+
+	if !C.xmp_get_property(x, schema, nameC, property, nil) {
+		return "", false
+	}
+	return StringGo(property), true
+}
+
 func SetProperty(x Xmp, schema *C.char, name string, value string,
 	optionBits C.uint32_t) bool {
 
@@ -88,6 +187,13 @@ func SetProperty(x Xmp, schema *C.char, name string, value string,
 	return bool(ret)
 }
 
+func DeleteProperty(x Xmp, schema *C.char, name string) bool {
+	nameC := C.CString(name)
+	defer C.free(unsafe.Pointer(nameC))
+
+	return bool(C.xmp_delete_property(x, schema, nameC))
+}
+
 func SetArrayItem(x Xmp, schema *C.char, name string, index int32,
 	value string, optionBits C.uint32_t) bool {
 
@@ -114,6 +220,358 @@ func AppendArrayItem(x Xmp, schema *C.char, name string,
 	return bool(ret)
 }
 
+// Get a date property. exempi doesn't tell a date without time zone
+// from one in UTC: both are returned in UTC. GetProperty returns the
+// text, without the offset if there is none.
+func GetPropertyDate(x Xmp, schema *C.char, name string) (time.Time, bool) {
+	nameC := C.CString(name)
+	defer C.free(unsafe.Pointer(nameC))
+
+	var d C.XmpDateTime
+	if !C.xmp_get_property_date(x, schema, nameC, &d, nil) {
+		return time.Time{}, false
+	}
+	offset := (int(d.tzHour)*60 + int(d.tzMinute)) * 60 * int(d.tzSign)
+	loc := time.UTC
+	if offset != 0 {
+		loc = time.FixedZone("", offset)
+	}
+	return time.Date(int(d.year), time.Month(d.month), int(d.day),
+		int(d.hour), int(d.minute), int(d.second), int(d.nanoSecond),
+		loc), true
+}
+
+// Set a date property, with the time zone of t.
+func SetPropertyDate(x Xmp, schema *C.char, name string, t time.Time,
+	optionBits C.uint32_t) bool {
+
+	nameC := C.CString(name)
+	defer C.free(unsafe.Pointer(nameC))
+
+	_, offset := t.Zone()
+	sign := 1
+	if offset < 0 {
+		sign, offset = -1, -offset
+	} else if offset == 0 {
+		sign = 0
+	}
+	d := C.XmpDateTime{
+		year:       C.int32_t(t.Year()),
+		month:      C.int32_t(t.Month()),
+		day:        C.int32_t(t.Day()),
+		hour:       C.int32_t(t.Hour()),
+		minute:     C.int32_t(t.Minute()),
+		second:     C.int32_t(t.Second()),
+		tzSign:     C.int32_t(sign),
+		tzHour:     C.int32_t(offset / 3600),
+		tzMinute:   C.int32_t(offset % 3600 / 60),
+		nanoSecond: C.int32_t(t.Nanosecond()),
+	}
+	return bool(C.xmp_set_property_date(x, schema, nameC, &d, optionBits))
+}
+
+func GetPropertyInt32(x Xmp, schema *C.char, name string) (int32, bool) {
+	nameC := C.CString(name)
+	defer C.free(unsafe.Pointer(nameC))
+
+	var value C.int32_t
+	ok := C.xmp_get_property_int32(x, schema, nameC, &value, nil)
+	return int32(value), bool(ok)
+}
+
+func SetPropertyInt32(x Xmp, schema *C.char, name string, value int32,
+	optionBits C.uint32_t) bool {
+
+	nameC := C.CString(name)
+	defer C.free(unsafe.Pointer(nameC))
+	return bool(C.xmp_set_property_int32(x, schema, nameC,
+		C.int32_t(value), optionBits))
+}
+
+func GetPropertyInt64(x Xmp, schema *C.char, name string) (int64, bool) {
+	nameC := C.CString(name)
+	defer C.free(unsafe.Pointer(nameC))
+
+	var value C.int64_t
+	ok := C.xmp_get_property_int64(x, schema, nameC, &value, nil)
+	return int64(value), bool(ok)
+}
+
+func SetPropertyInt64(x Xmp, schema *C.char, name string, value int64,
+	optionBits C.uint32_t) bool {
+
+	nameC := C.CString(name)
+	defer C.free(unsafe.Pointer(nameC))
+	return bool(C.xmp_set_property_int64(x, schema, nameC,
+		C.int64_t(value), optionBits))
+}
+
+func GetPropertyFloat(x Xmp, schema *C.char, name string) (float64, bool) {
+	nameC := C.CString(name)
+	defer C.free(unsafe.Pointer(nameC))
+
+	var value C.double
+	ok := C.xmp_get_property_float(x, schema, nameC, &value, nil)
+	return float64(value), bool(ok)
+}
+
+func SetPropertyFloat(x Xmp, schema *C.char, name string, value float64,
+	optionBits C.uint32_t) bool {
+
+	nameC := C.CString(name)
+	defer C.free(unsafe.Pointer(nameC))
+	return bool(C.xmp_set_property_float(x, schema, nameC,
+		C.double(value), optionBits))
+}
+
+// Get a boolean property, "True" or "False".
+func GetPropertyBool(x Xmp, schema *C.char, name string) (value bool,
+	ok bool) {
+
+	nameC := C.CString(name)
+	defer C.free(unsafe.Pointer(nameC))
+
+	var valueC C.bool
+	ok = bool(C.xmp_get_property_bool(x, schema, nameC, &valueC, nil))
+	return bool(valueC), ok
+}
+
+func SetPropertyBool(x Xmp, schema *C.char, name string, value bool,
+	optionBits C.uint32_t) bool {
+
+	nameC := C.CString(name)
+	defer C.free(unsafe.Pointer(nameC))
+	return bool(C.xmp_set_property_bool(x, schema, nameC, C.bool(value),
+		optionBits))
+}
+
+// Get a rational property, like "7/2".
+func GetPropertyRational(x Xmp, schema *C.char, name string) (Rational,
+	bool) {
+
+	value, ok := GetProperty(x, schema, name)
+	if !ok {
+		return Rational{}, false
+	}
+	r, err := ParseRational(value)
+	return r, err == nil
+}
+
+func SetPropertyRational(x Xmp, schema *C.char, name string, value Rational,
+	optionBits C.uint32_t) bool {
+
+	return SetProperty(x, schema, name, value.String(), optionBits)
+}
+
+// The language of the default item of a lang-alt array.
+const X_DEFAULT = "x-default"
+
+// Get the item of the lang-alt array matching the languages, like
+// "en" and "en-CA". actualLang is the language of the item found.
+func GetLocalizedText(x Xmp, schema *C.char, name string, genericLang string,
+	specificLang string) (value string, actualLang string, ok bool) {
+
+	nameC := C.CString(name)
+	defer C.free(unsafe.Pointer(nameC))
+	genericC := C.CString(genericLang)
+	defer C.free(unsafe.Pointer(genericC))
+	specificC := C.CString(specificLang)
+	defer C.free(unsafe.Pointer(specificC))
+	actual := StringNew()
+	defer StringFree(actual)
+	item := StringNew()
+	defer StringFree(item)
+
+	if !C.xmp_get_localized_text(x, schema, nameC, genericC, specificC,
+		actual, item, nil) {
+		return "", "", false
+	}
+	return StringGo(item), StringGo(actual), true
+}
+
+// Set the item of the lang-alt array for the languages, creating the
+// array if needed. Setting X_DEFAULT also sets the matching item.
+func SetLocalizedText(x Xmp, schema *C.char, name string, genericLang string,
+	specificLang string, value string, optionBits C.uint32_t) bool {
+
+	nameC := C.CString(name)
+	defer C.free(unsafe.Pointer(nameC))
+	genericC := C.CString(genericLang)
+	defer C.free(unsafe.Pointer(genericC))
+	specificC := C.CString(specificLang)
+	defer C.free(unsafe.Pointer(specificC))
+	valueC := C.CString(value)
+	defer C.free(unsafe.Pointer(valueC))
+
+	return bool(C.xmp_set_localized_text(x, schema, nameC, genericC,
+		specificC, valueC, optionBits))
+}
+
+func DeleteLocalizedText(x Xmp, schema *C.char, name string,
+	genericLang string, specificLang string) bool {
+
+	nameC := C.CString(name)
+	defer C.free(unsafe.Pointer(nameC))
+	genericC := C.CString(genericLang)
+	defer C.free(unsafe.Pointer(genericC))
+	specificC := C.CString(specificLang)
+	defer C.free(unsafe.Pointer(specificC))
+
+	return bool(C.xmp_delete_localized_text(x, schema, nameC, genericC,
+		specificC))
+}
+
+// Return the prefix registered for the namespace, without the colon.
+func NamespacePrefix(ns *C.char) (string, bool) {
+	prefix := StringNew()
+	defer StringFree(prefix)
+	if !C.xmp_namespace_prefix(ns, prefix) {
+		return "", false
+	}
+	return strings.TrimSuffix(StringGo(prefix), ":"), true
+}
+
+// Return the path of a field of the struct name, like "Flash/exif:Fired".
+func StructFieldPath(structName string, fieldSchema *C.char,
+	fieldName string) (string, bool) {
+
+	prefix, ok := NamespacePrefix(fieldSchema)
+	if !ok {
+		return "", false
+	}
+	return structName + "/" + prefix + ":" + fieldName, true
+}
+
+// Return the path of a qualifier of the property name, like
+// "Item/?xml:lang".
+func QualifierPath(propName string, qualSchema *C.char,
+	qualName string) (string, bool) {
+
+	prefix, ok := NamespacePrefix(qualSchema)
+	if !ok {
+		return "", false
+	}
+	return propName + "/?" + prefix + ":" + qualName, true
+}
+
+func GetStructField(x Xmp, schema *C.char, structName string,
+	fieldSchema *C.char, fieldName string) (string, bool) {
+
+	path, ok := StructFieldPath(structName, fieldSchema, fieldName)
+	if !ok {
+		return "", false
+	}
+	return GetProperty(x, schema, path)
+}
+
+// Set a field of the struct, creating the struct if needed.
+func SetStructField(x Xmp, schema *C.char, structName string,
+	fieldSchema *C.char, fieldName string, value string,
+	optionBits C.uint32_t) bool {
+
+	path, ok := StructFieldPath(structName, fieldSchema, fieldName)
+	if !ok {
+		return false
+	}
+	if _, found := GetProperty(x, schema, structName); !found &&
+		!SetProperty(x, schema, structName, "", PROP_VALUE_IS_STRUCT) {
+		return false
+	}
+	return SetProperty(x, schema, path, value, optionBits)
+}
+
+func DeleteStructField(x Xmp, schema *C.char, structName string,
+	fieldSchema *C.char, fieldName string) bool {
+
+	path, ok := StructFieldPath(structName, fieldSchema, fieldName)
+	if !ok {
+		return false
+	}
+	return DeleteProperty(x, schema, path)
+}
+
+func GetQualifier(x Xmp, schema *C.char, propName string,
+	qualSchema *C.char, qualName string) (string, bool) {
+
+	path, ok := QualifierPath(propName, qualSchema, qualName)
+	if !ok {
+		return "", false
+	}
+	return GetProperty(x, schema, path)
+}
+
+// Set a qualifier of the property, that must exist.
+func SetQualifier(x Xmp, schema *C.char, propName string,
+	qualSchema *C.char, qualName string, value string,
+	optionBits C.uint32_t) bool {
+
+	path, ok := QualifierPath(propName, qualSchema, qualName)
+	if !ok {
+		return false
+	}
+	return SetProperty(x, schema, path, value, optionBits)
+}
+
+func DeleteQualifier(x Xmp, schema *C.char, propName string,
+	qualSchema *C.char, qualName string) bool {
+
+	path, ok := QualifierPath(propName, qualSchema, qualName)
+	if !ok {
+		return false
+	}
+	return DeleteProperty(x, schema, path)
+}
+
+// Create an iterator. schema and propName can be nil and ""
+// respectively to iterate everything.
+func IteratorNew(x Xmp, schema *C.char, propName string,
+	options C.XmpIterOptions) Iterator {
+
+	var propNameC *C.char
+	if propName != "" {
+		propNameC = C.CString(propName)
+		defer C.free(unsafe.Pointer(propNameC))
+	}
+	return Iterator(C.xmp_iterator_new(x, schema, propNameC, options))
+}
+
+func IteratorFree(iter Iterator) {
+	C.xmp_iterator_free(iter)
+}
+
+// Move to the next node. Return false when done.
+func IteratorNext(iter Iterator, schema String, propName String,
+	propValue String, options *uint32) bool {
+
+	var optionsC C.uint32_t
+	ret := C.xmp_iterator_next(iter, schema, propName, propValue,
+		&optionsC)
+	if options != nil {
+		*options = uint32(optionsC)
+	}
+	return bool(ret)
+}
+
+// Open a file for its XMP. Return nil on failure.
+func FilesOpenNew(path string, options C.XmpOpenFileOptions) File {
+	pathC := C.CString(path)
+	defer C.free(unsafe.Pointer(pathC))
+	return File(C.xmp_files_open_new(pathC, options))
+}
+
+// Get the XMP from the file. Return nil if there is none.
+func FilesGetNewXmp(xf File) Xmp {
+	return Xmp(C.xmp_files_get_new_xmp(xf))
+}
+
+func FilesClose(xf File, options C.XmpCloseFileOptions) bool {
+	return bool(C.xmp_files_close(xf, options))
+}
+
+func FilesFree(xf File) {
+	C.xmp_files_free(xf)
+}
+
 func StringNew() String {
 	return String(C.xmp_string_new())
 }
@@ -131,4 +589,5 @@ func init () {
 	C.xmp_init()
 
 	RegisterNamespace(NS_ANALOG, "analog", String(nil))
+	RegisterNamespace(NS_E4F, "e4f", String(nil))
 }
@@ -0,0 +1,411 @@
+package xmp
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A XMP packet, as made by Marshal.
+type Meta struct {
+	Xmp Xmp
+}
+
+func (m *Meta) Free() {
+	Free(m.Xmp)
+	m.Xmp = nil
+}
+
+// A parsed xmp struct tag, like `xmp:"exif:FNumber,rational"`. The
+// options are:
+//   - bag, seq: a slice is written as an unordered or ordered array.
+//     bag is the default.
+//   - alt: a string is written as the x-default item of a lang-alt
+//     array.
+//   - rational: a float is written as a rational, like "7/2".
+//   - omitempty: a zero value isn't written.
+//
+// A struct field, other than a time.Time or a Rational, is written as
+// a XMP struct, its own fields being the fields of the struct.
+type fieldTag struct {
+	Prefix string
+	Name   string
+
+	Bag, Seq, Alt, Rational, OmitEmpty bool
+}
+
+func parseTag(tag string) (t fieldTag, err error) {
+	parts := strings.Split(tag, ",")
+	prefix, name, found := strings.Cut(parts[0], ":")
+	if !found || prefix == "" || name == "" {
+		return t, fmt.Errorf("xmp tag %q isn't prefix:name", tag)
+	}
+	t.Prefix, t.Name = prefix, name
+	for _, option := range parts[1:] {
+		switch option {
+		case "bag":
+			t.Bag = true
+		case "seq":
+			t.Seq = true
+		case "alt":
+			t.Alt = true
+		case "rational":
+			t.Rational = true
+		case "omitempty":
+			t.OmitEmpty = true
+		default:
+			return t, fmt.Errorf("xmp tag %q: unknown option %q", tag,
+				option)
+		}
+	}
+	if t.Bag && t.Seq {
+		return t, fmt.Errorf("xmp tag %q: both bag and seq", tag)
+	}
+	return t, nil
+}
+
+// A tagged field of a struct.
+type field struct {
+	tag   fieldTag
+	ns    Namespace
+	value reflect.Value
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	rationalType = reflect.TypeOf(Rational{})
+)
+
+// Whether t is written as a XMP struct.
+func isStruct(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != timeType && t != rationalType
+}
+
+// Return the tagged fields of the struct v, resolving their prefix.
+func taggedFields(v reflect.Value) ([]field, error) {
+	var fields []field
+	for i := 0; i < v.NumField(); i++ {
+		sf := v.Type().Field(i)
+		tagS, found := sf.Tag.Lookup("xmp")
+		if !found || tagS == "-" || !sf.IsExported() {
+			continue
+		}
+		tag, err := parseTag(tagS)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", sf.Name, err)
+		}
+		// Through the prefix registry, see RegisterPrefix.
+		ns, found := PrefixNamespace(tag.Prefix)
+		if !found {
+			return nil, fmt.Errorf("%s: unknown prefix %q", sf.Name,
+				tag.Prefix)
+		}
+		fields = append(fields, field{tag, ns, v.Field(i)})
+	}
+	return fields, nil
+}
+
+// Return the struct v points to.
+func structValue(v any) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return rv, fmt.Errorf("xmp: %T isn't a struct", v)
+	}
+	return rv, nil
+}
+
+// Format a scalar value as XMP text.
+func formatValue(v reflect.Value, tag fieldTag) (string, error) {
+	switch v.Type() {
+	case timeType:
+		return v.Interface().(time.Time).Format(time.RFC3339Nano), nil
+	case rationalType:
+		return v.Interface().(Rational).String(), nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		if v.Bool() {
+			return "True", nil
+		}
+		return "False", nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		if tag.Rational {
+			return FloatRational(v.Float()).String(), nil
+		}
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), nil
+	}
+	return "", fmt.Errorf("can't write a %s", v.Type())
+}
+
+// Parse the XMP text s into the scalar v.
+func parseValue(s string, v reflect.Value) error {
+	switch v.Type() {
+	case timeType:
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	case rationalType:
+		r, err := ParseRational(s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(r))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		f, err := parseNumber(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(math.Round(f)))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		f, err := parseNumber(s)
+		if err != nil || f < 0 {
+			return fmt.Errorf("invalid number %q", s)
+		}
+		v.SetUint(uint64(math.Round(f)))
+	case reflect.Float32, reflect.Float64:
+		f, err := parseNumber(s)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("can't read a %s", v.Type())
+	}
+	return nil
+}
+
+// Parse a number, that can be a rational.
+func parseNumber(s string) (float64, error) {
+	if strings.Contains(s, "/") {
+		r, err := ParseRational(s)
+		return r.Float(), err
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	return f, nil
+}
+
+// Write the tagged fields of the struct v to a new XMP packet.
+func Marshal(v any) (*Meta, error) {
+	m := &Meta{NewEmpty()}
+	if err := MarshalInto(m.Xmp, v); err != nil {
+		m.Free()
+		return nil, err
+	}
+	return m, nil
+}
+
+// Write the tagged fields of the struct v to x.
+func MarshalInto(x Xmp, v any) error {
+	rv, err := structValue(v)
+	if err != nil {
+		return err
+	}
+	fields, err := taggedFields(rv)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		if err := f.marshal(x); err != nil {
+			return fmt.Errorf("%s:%s: %w", f.tag.Prefix, f.tag.Name, err)
+		}
+	}
+	return nil
+}
+
+func (f field) marshal(x Xmp) error {
+	v := f.value
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if f.tag.OmitEmpty && v.IsZero() {
+		return nil
+	}
+
+	switch {
+	case f.tag.Alt:
+		if v.Kind() != reflect.String {
+			return fmt.Errorf("alt needs a string")
+		}
+		SetLocalizedText(x, f.ns, f.tag.Name, "", X_DEFAULT, v.String(), 0)
+		return nil
+	case v.Kind() == reflect.Slice:
+		DeleteProperty(x, f.ns, f.tag.Name)
+		for i := 0; i < v.Len(); i++ {
+			item, err := formatValue(v.Index(i), f.tag)
+			if err != nil {
+				return err
+			}
+			if f.tag.Seq {
+				AppendArrayItem(x, f.ns, f.tag.Name,
+					PROP_ARRAY_IS_ORDERED, item, 0)
+			} else {
+				AppendArrayItem(x, f.ns, f.tag.Name,
+					PROP_VALUE_IS_ARRAY, item, 0)
+			}
+		}
+		return nil
+	case isStruct(v.Type()):
+		fields, err := taggedFields(v)
+		if err != nil {
+			return err
+		}
+		for _, sub := range fields {
+			if sub.tag.OmitEmpty && sub.value.IsZero() {
+				continue
+			}
+			value, err := formatValue(sub.value, sub.tag)
+			if err != nil {
+				return err
+			}
+			SetStructField(x, f.ns, f.tag.Name, sub.ns, sub.tag.Name,
+				value, 0)
+		}
+		return nil
+	}
+
+	switch {
+	case v.Type() == timeType:
+		SetPropertyDate(x, f.ns, f.tag.Name, v.Interface().(time.Time), 0)
+	case v.Type() == rationalType:
+		SetPropertyRational(x, f.ns, f.tag.Name,
+			v.Interface().(Rational), 0)
+	case v.Kind() == reflect.Bool:
+		SetPropertyBool(x, f.ns, f.tag.Name, v.Bool(), 0)
+	case v.CanInt():
+		SetPropertyInt64(x, f.ns, f.tag.Name, v.Int(), 0)
+	case v.CanFloat() && !f.tag.Rational:
+		SetPropertyFloat(x, f.ns, f.tag.Name, v.Float(), 0)
+	default:
+		value, err := formatValue(v, f.tag)
+		if err != nil {
+			return err
+		}
+		SetProperty(x, f.ns, f.tag.Name, value, 0)
+	}
+	return nil
+}
+
+// Read the tagged fields of the struct v points to from the XMP
+// packet. Fields of missing properties are left as they are. A date
+// without time zone is read in UTC, see GetPropertyDate: read it into
+// a string to tell.
+func Unmarshal(m *Meta, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("xmp: Unmarshal needs a pointer, not %T", v)
+	}
+	rv, err := structValue(v)
+	if err != nil {
+		return err
+	}
+	fields, err := taggedFields(rv)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		if err := f.unmarshal(m.Xmp); err != nil {
+			return fmt.Errorf("%s:%s: %w", f.tag.Prefix, f.tag.Name, err)
+		}
+	}
+	return nil
+}
+
+func (f field) unmarshal(x Xmp) error {
+	if _, found := GetProperty(x, f.ns, f.tag.Name); !found {
+		return nil
+	}
+	v := f.value
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+
+	switch {
+	case f.tag.Alt:
+		if v.Kind() != reflect.String {
+			return fmt.Errorf("alt needs a string")
+		}
+		value, _, _ := GetLocalizedText(x, f.ns, f.tag.Name, "", X_DEFAULT)
+		v.SetString(value)
+		return nil
+	case v.Kind() == reflect.Slice:
+		items := reflect.MakeSlice(v.Type(), 0, 0)
+		for i := 1; ; i++ {
+			item, found := GetProperty(x, f.ns,
+				fmt.Sprintf("%s[%d]", f.tag.Name, i))
+			if !found {
+				break
+			}
+			iv := reflect.New(v.Type().Elem()).Elem()
+			if err := parseValue(item, iv); err != nil {
+				return err
+			}
+			items = reflect.Append(items, iv)
+		}
+		v.Set(items)
+		return nil
+	case isStruct(v.Type()):
+		fields, err := taggedFields(v)
+		if err != nil {
+			return err
+		}
+		for _, sub := range fields {
+			value, found := GetStructField(x, f.ns, f.tag.Name, sub.ns,
+				sub.tag.Name)
+			if !found {
+				continue
+			}
+			if err := parseValue(value, sub.value); err != nil {
+				return fmt.Errorf("%s:%s: %w", sub.tag.Prefix,
+					sub.tag.Name, err)
+			}
+		}
+		return nil
+	case v.Type() == timeType:
+		if t, found := GetPropertyDate(x, f.ns, f.tag.Name); found {
+			v.Set(reflect.ValueOf(t))
+		}
+		return nil
+	}
+
+	value, _ := GetProperty(x, f.ns, f.tag.Name)
+	return parseValue(value, v)
+}
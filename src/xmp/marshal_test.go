@@ -0,0 +1,179 @@
+package xmp
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseTag(t *testing.T) {
+	tag, err := parseTag("exif:FNumber,rational,omitempty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := fieldTag{Prefix: "exif", Name: "FNumber", Rational: true,
+		OmitEmpty: true}
+	if tag != expected {
+		t.Errorf("parseTag = %+v, expected %+v", tag, expected)
+	}
+	for _, s := range []string{"FNumber", "exif:", ":FNumber",
+		"dc:creator,list", "dc:creator,bag,seq"} {
+		if _, err := parseTag(s); err == nil {
+			t.Errorf("parseTag(%q) should fail", s)
+		}
+	}
+}
+
+func TestFormatParseValue(t *testing.T) {
+	date := time.Date(2013, 6, 30, 17, 51, 53, 0,
+		time.FixedZone("", -4*3600))
+	tests := []struct {
+		value    any
+		tag      fieldTag
+		expected string
+	}{
+		{"Canon", fieldTag{}, "Canon"},
+		{true, fieldTag{}, "True"},
+		{36, fieldTag{}, "36"},
+		{uint16(400), fieldTag{}, "400"},
+		{3.5, fieldTag{}, "3.5"},
+		{3.5, fieldTag{Rational: true}, "7/2"},
+		{Rational{21, 2}, fieldTag{}, "21/2"},
+		{date, fieldTag{}, "2013-06-30T17:51:53-04:00"},
+	}
+	for _, test := range tests {
+		s, err := formatValue(reflect.ValueOf(test.value), test.tag)
+		if err != nil || s != test.expected {
+			t.Errorf("formatValue(%v) = %q, %v, expected %q", test.value,
+				s, err, test.expected)
+			continue
+		}
+		parsed := reflect.New(reflect.TypeOf(test.value)).Elem()
+		if err := parseValue(s, parsed); err != nil {
+			t.Errorf("parseValue(%q): %v", s, err)
+		} else if !reflect.DeepEqual(parsed.Interface(), test.value) &&
+			!(test.value == date && parsed.Interface().(time.Time).Equal(date)) {
+			t.Errorf("parseValue(%q) = %v, expected %v", s,
+				parsed.Interface(), test.value)
+		}
+	}
+
+	var f float64
+	if err := parseValue("9/5", reflect.ValueOf(&f).Elem()); err != nil ||
+		f != 1.8 {
+		t.Errorf("parseValue(\"9/5\") = %v, %v, expected 1.8", f, err)
+	}
+	var i int
+	if err := parseValue("50/1", reflect.ValueOf(&i).Elem()); err != nil ||
+		i != 50 {
+		t.Errorf("parseValue(\"50/1\") = %v, %v, expected 50", i, err)
+	}
+	if _, err := formatValue(reflect.ValueOf([]int{1}), fieldTag{}); err == nil {
+		t.Errorf("formatValue of a slice should fail")
+	}
+	var u uint
+	if err := parseValue("-1", reflect.ValueOf(&u).Elem()); err == nil {
+		t.Errorf("parseValue(\"-1\") into a uint should fail")
+	}
+	var ints []int
+	if err := parseValue("1", reflect.ValueOf(&ints).Elem()); err == nil {
+		t.Errorf("parseValue into a slice should fail")
+	}
+}
+
+func TestMarshalNotStruct(t *testing.T) {
+	if _, err := Marshal(42); err == nil {
+		t.Errorf("Marshal(42) should fail")
+	}
+	i := 42
+	if err := Unmarshal(&Meta{}, &i); err == nil {
+		t.Errorf("Unmarshal of an int should fail")
+	}
+	var s struct{}
+	if err := Unmarshal(&Meta{}, s); err == nil {
+		t.Errorf("Unmarshal of a struct value should fail")
+	}
+}
+
+type testContact struct {
+	City  string `xmp:"Iptc4xmpCore:CiAdrCity"`
+	Email string `xmp:"Iptc4xmpCore:CiEmailWork,omitempty"`
+}
+
+type testMeta struct {
+	Make     string      `xmp:"tiff:Make"`
+	Title    string      `xmp:"dc:title,alt"`
+	Creators []string    `xmp:"dc:creator,seq"`
+	Subjects []string    `xmp:"dc:subject"`
+	FNumber  float64     `xmp:"exif:FNumber,rational"`
+	Focal    Rational    `xmp:"exif:FocalLength"`
+	Iso      int         `xmp:"exifEX:RecommendedExposureIndex"`
+	Marked   bool        `xmp:"xmpRights:Marked"`
+	Taken    time.Time   `xmp:"exif:DateTimeOriginal"`
+	Model    string      `xmp:"tiff:Model,omitempty"`
+	Lens     *string     `xmp:"aux:Lens"`
+	Contact  testContact `xmp:"Iptc4xmpCore:CreatorContactInfo"`
+	Ignored  string      `xmp:"-"`
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	lens := "Nikkor 50mm"
+	written := testMeta{
+		Make:     "Nikon",
+		Title:    "Harbour",
+		Creators: []string{"Ada", "Grace"},
+		Subjects: []string{"sea"},
+		FNumber:  3.5,
+		Focal:    Rational{50, 1},
+		Iso:      1600,
+		Marked:   true,
+		Taken: time.Date(2013, 6, 30, 17, 51, 53, 0,
+			time.FixedZone("", -4*3600)),
+		Lens:    &lens,
+		Contact: testContact{City: "Montreal"},
+		Ignored: "ignored",
+	}
+	m, err := Marshal(&written)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Free()
+
+	if value, _ := GetProperty(m.Xmp, NS_EXIF, "FNumber"); value != "7/2" {
+		t.Errorf("exif:FNumber is %q", value)
+	}
+	if value, found := GetProperty(m.Xmp, NS_TIFF, "Model"); found {
+		t.Errorf("Empty tiff:Model written as %q", value)
+	}
+	ns, _ := PrefixNamespace("Iptc4xmpCore")
+	if value, found := GetStructField(m.Xmp, ns, "CreatorContactInfo",
+		ns, "CiEmailWork"); found {
+		t.Errorf("Empty email written as %q", value)
+	}
+
+	var read testMeta
+	if err := Unmarshal(m, &read); err != nil {
+		t.Fatal(err)
+	}
+	written.Ignored = ""
+	if !read.Taken.Equal(written.Taken) {
+		t.Errorf("Read date %v, expected %v", read.Taken, written.Taken)
+	}
+	read.Taken = written.Taken
+	if !reflect.DeepEqual(read, written) {
+		t.Errorf("Read %+v, expected %+v", read, written)
+	}
+
+	// Into an existing packet, replacing the arrays.
+	written.Creators = []string{"Jane"}
+	if err := MarshalInto(m.Xmp, &written); err != nil {
+		t.Fatal(err)
+	}
+	read = testMeta{}
+	if err := Unmarshal(m, &read); err != nil {
+		t.Fatal(err)
+	}
+	if len(read.Creators) != 1 || read.Creators[0] != "Jane" {
+		t.Errorf("Read creators %q", read.Creators)
+	}
+}
@@ -0,0 +1,75 @@
+package xmp
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// An EXIF rational, written "Num/Den" in XMP.
+type Rational struct {
+	Num int64
+	Den int64
+}
+
+// The largest denominator FloatRational uses.
+const maxRationalDen = 10000
+
+// Return the rational closest to f, with the smallest denominator
+// matching it within 1e-9. f/3.5 is 7/2 and 10.5mm is 21/2.
+func FloatRational(f float64) Rational {
+	sign := int64(1)
+	if f < 0 {
+		sign, f = -1, -f
+	}
+	// Convergents of the continued fraction of f.
+	num, den := int64(1), int64(0)
+	prevNum, prevDen := int64(0), int64(1)
+	x := f
+	for {
+		a := math.Floor(x)
+		if a > math.MaxInt32 {
+			break
+		}
+		nextNum := int64(a)*num + prevNum
+		nextDen := int64(a)*den + prevDen
+		if nextDen > maxRationalDen {
+			break
+		}
+		num, den, prevNum, prevDen = nextNum, nextDen, num, den
+		if math.Abs(float64(num)/float64(den)-f) < 1e-9 || x == a {
+			break
+		}
+		x = 1 / (x - a)
+	}
+	if den == 0 {
+		return Rational{sign * int64(math.Round(f)), 1}
+	}
+	return Rational{sign * num, den}
+}
+
+// Parse "Num/Den", or an integer.
+func ParseRational(s string) (Rational, error) {
+	numS, denS, found := strings.Cut(strings.TrimSpace(s), "/")
+	num, err := strconv.ParseInt(numS, 10, 64)
+	if err != nil {
+		return Rational{}, fmt.Errorf("invalid rational %q", s)
+	}
+	den := int64(1)
+	if found {
+		den, err = strconv.ParseInt(denS, 10, 64)
+		if err != nil || den == 0 {
+			return Rational{}, fmt.Errorf("invalid rational %q", s)
+		}
+	}
+	return Rational{num, den}, nil
+}
+
+func (r Rational) Float() float64 {
+	return float64(r.Num) / float64(r.Den)
+}
+
+func (r Rational) String() string {
+	return fmt.Sprintf("%d/%d", r.Num, r.Den)
+}
@@ -0,0 +1,56 @@
+package xmp
+
+import "testing"
+
+func TestFloatRational(t *testing.T) {
+	tests := []struct {
+		f        float64
+		expected Rational
+	}{
+		{3.5, Rational{7, 2}},
+		{10.5, Rational{21, 2}},
+		{1.8, Rational{9, 5}},
+		{5.6, Rational{28, 5}},
+		{6.3, Rational{63, 10}},
+		{50, Rational{50, 1}},
+		{1.0 / 3, Rational{1, 3}},
+		{0, Rational{0, 1}},
+		{-12.5, Rational{-25, 2}},
+	}
+	for _, test := range tests {
+		if r := FloatRational(test.f); r != test.expected {
+			t.Errorf("FloatRational(%v) = %v, expected %v", test.f, r,
+				test.expected)
+		}
+	}
+}
+
+func TestParseRational(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected Rational
+	}{
+		{"7/2", Rational{7, 2}},
+		{"35/10", Rational{35, 10}},
+		{"50", Rational{50, 1}},
+		{"-1/3", Rational{-1, 3}},
+	}
+	for _, test := range tests {
+		r, err := ParseRational(test.s)
+		if err != nil || r != test.expected {
+			t.Errorf("ParseRational(%q) = %v, %v, expected %v", test.s, r,
+				err, test.expected)
+		}
+	}
+	for _, s := range []string{"", "1/0", "3.5", "a/2"} {
+		if _, err := ParseRational(s); err == nil {
+			t.Errorf("ParseRational(%q) should fail", s)
+		}
+	}
+	if f := (Rational{7, 2}).Float(); f != 3.5 {
+		t.Errorf("Float() = %v, expected 3.5", f)
+	}
+	if s := (Rational{21, 2}).String(); s != "21/2" {
+		t.Errorf("String() = %q, expected \"21/2\"", s)
+	}
+}
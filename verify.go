@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"gitlab.com/photo/e4f-go/src/e4f"
+	"gitlab.com/photo/e4f-go/src/xmp"
+)
+
+type ownedProperty struct {
+	ns   xmp.Namespace
+	name string
+}
+
+// The properties updateScanXmp replaces, those of the mapping. Any of
+// them found in a scan but not generated is reported as extra. The
+// rights are only owned with -rights, else they are the user's.
+func ownedProperties() (owned []ownedProperty) {
+	for i := range options.Mapping.Properties {
+		prop := &options.Mapping.Properties[i]
+		if len(options.Rights) == 0 && prop.fromRights() {
+			continue
+		}
+		owned = append(owned, ownedProperty{prop.ns, prop.name})
+	}
+	return
+}
+
+// Collect the leaf values of the owned properties, keyed by path.
+func ownedLeaves(x xmp.Xmp) map[string]string {
+	leaves := make(map[string]string)
+
+	schema := xmp.StringNew()
+	defer xmp.StringFree(schema)
+	path := xmp.StringNew()
+	defer xmp.StringFree(path)
+	value := xmp.StringNew()
+	defer xmp.StringFree(value)
+
+	for _, prop := range ownedProperties() {
+		iter := xmp.IteratorNew(x, prop.ns, prop.name,
+			xmp.ITER_JUSTLEAFNODES|xmp.ITER_OMITQUALIFIERS)
+		if iter == nil {
+			continue
+		}
+		for xmp.IteratorNext(iter, schema, path, value, nil) {
+			leaves[xmp.StringGo(path)] = xmp.StringGo(value)
+		}
+		xmp.IteratorFree(iter)
+	}
+	return leaves
+}
+
+// Compare the XMP found with the XMP expected and return the
+// differences, one per line. Empty if they match.
+func diffXmp(expected xmp.Xmp, found xmp.Xmp) (diffs []string) {
+	want := ownedLeaves(expected)
+	got := ownedLeaves(found)
+
+	var paths []string
+	for path := range want {
+		paths = append(paths, path)
+	}
+	for path := range got {
+		if _, ok := want[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		w, inWant := want[path]
+		g, inGot := got[path]
+		switch {
+		case !inGot:
+			diffs = append(diffs,
+				fmt.Sprintf("missing   %s = %q", path, w))
+		case !inWant:
+			diffs = append(diffs,
+				fmt.Sprintf("extra     %s = %q", path, g))
+		case w != g:
+			diffs = append(diffs,
+				fmt.Sprintf("different %s = %q, expected %q",
+					path, g, w))
+		}
+	}
+	return
+}
+
+// Compare the XMP found in scan with the XMP writing the frame with
+// the scanner policy gives, see updateScanXmp.
+func verifyScan(frame *e4f.Frame, scan string, found xmp.Xmp,
+	policy scannerPolicy) []string {
+
+	expected := xmp.NewEmpty()
+	defer xmp.Free(expected)
+	updateScanXmp(expected, frame, readScanner(scan, found, frame), policy)
+	return diffXmp(expected, found)
+}
+
+// Verify the XMP of the scans in dir against the roll, written with the
+// scanner policy. Print the drift and return false if there is any.
+func verifyRoll(db *e4f.E4fDb, roll *e4f.ExposedRoll, dir string,
+	policy scannerPolicy) bool {
+
+	exps := db.ExposuresForRoll(roll.Id)
+	scans, err := matchScans(dir, exps)
+	if err != nil {
+		fmt.Printf("%s: %s\n", dir, err)
+		return false
+	}
+
+	ok := true
+	for i, exp := range exps {
+		scan := scans[i]
+		if scan == "" {
+			fmt.Printf("Frame %d: no scan\n", i+1)
+			ok = false
+			continue
+		}
+
+		found := readScanXmp(scan)
+		if found == nil {
+			fmt.Printf("Frame %d, %s: no XMP\n", i+1, scan)
+			ok = false
+			continue
+		}
+
+		diffs := verifyScan(db.Frame(roll, exp, i), scan, found, policy)
+		if len(diffs) > 0 {
+			ok = false
+			fmt.Printf("Frame %d, %s:\n", i+1, scan)
+			for _, diff := range diffs {
+				fmt.Printf("\t%s\n", diff)
+			}
+		}
+		xmp.Free(found)
+	}
+	return ok
+}
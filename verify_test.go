@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"gitlab.com/photo/e4f-go/src/e4f"
+	"gitlab.com/photo/e4f-go/src/xmp"
+)
+
+// A scan written with a scanner policy verifies with the same policy.
+func TestVerifyScan(t *testing.T) {
+	options.Mapping = defaultMapping()
+	db := e4f.Parse("samples/export-Roll-20130630_203650.xml")
+	roll := db.ExposedRolls[0]
+	exp := db.ExposuresForRoll(roll.Id)[0]
+	frame := db.Frame(roll, exp, 0)
+
+	for _, policy := range []scannerPolicy{scannerMove, scannerKeep,
+		scannerOverwrite} {
+
+		x := xmp.NewEmpty()
+		xmp.SetProperty(x, xmp.NS_TIFF, "Make", "Nikon", 0)
+		xmp.SetProperty(x, xmp.NS_TIFF, "Model", "LS-5000", 0)
+		xmp.SetProperty(x, xmp.NS_EXIF, "DateTimeOriginal",
+			"2020-01-02T03:04:05", 0)
+		updateScanXmp(x, frame, readScanner("", x, frame), policy)
+
+		if diffs := verifyScan(frame, "", x, policy); len(diffs) > 0 {
+			t.Errorf("%s: drift %q", policy, diffs)
+		}
+		if policy == scannerKeep {
+			if diffs := verifyScan(frame, "", x,
+				scannerOverwrite); len(diffs) == 0 {
+				t.Error("Scanner kept, no drift with overwrite")
+			}
+		}
+		xmp.Free(x)
+	}
+}
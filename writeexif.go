@@ -0,0 +1,120 @@
+package main
+
+import (
+	"math"
+	"strings"
+
+	"gitlab.com/photo/e4f-go/src/e4f"
+	"gitlab.com/photo/e4f-go/src/exif"
+	"gitlab.com/photo/e4f-go/src/xmp"
+)
+
+// Return the EXIF rational closest to the absolute value of f.
+func exifRational(f float64) [2]uint32 {
+	r := xmp.FloatRational(math.Abs(f))
+	return [2]uint32{uint32(r.Num), uint32(r.Den)}
+}
+
+// Split a coordinate into degrees, minutes and seconds rationals.
+func gpsCoordToRationals(f float64) exif.Value {
+	f = math.Abs(f)
+	degs := math.Floor(f)
+	minutes := math.Floor((f - degs) * 60)
+	seconds := ((f-degs)*60 - minutes) * 60
+	return exif.Rational([2]uint32{uint32(degs), 1},
+		[2]uint32{uint32(minutes), 1},
+		exifRational(seconds))
+}
+
+// Generate the EXIF tags for a frame, from the same data as the
+// built-in mapping. -mapping doesn't change them.
+func frameToExif(frame *e4f.Frame) *exif.Tags {
+	tags := exif.NewTags()
+	exp := frame.Exposure
+
+	if camera := frame.Camera; camera != nil {
+		if mk := frame.CameraMakeName(); mk != "" {
+			tags.IFD0[exif.TagMake] = exif.Ascii(mk)
+		}
+		if camera.Title != "" {
+			tags.IFD0[exif.TagModel] = exif.Ascii(camera.Title)
+		}
+	}
+
+	if names := frame.ArtistNames(); len(names) > 0 {
+		tags.IFD0[exif.TagArtist] = exif.Ascii(strings.Join(names, "; "))
+	}
+	if r := frameRights(frame); r != nil && r.Copyright != "" {
+		if copyright, ok := expandYear(r.Copyright, frame); ok {
+			tags.IFD0[exif.TagCopyright] = exif.Ascii(copyright)
+		}
+	}
+
+	if t, ok := frame.Time(); ok {
+		tags.Exif[exif.TagDateTimeOriginal] =
+			exif.Ascii(t.Format(e4f.ExifTimeLayout))
+	}
+	if loc, resolved := frameZone(frame); resolved {
+		if t, ok := frame.TimeIn(loc); ok {
+			tags.Exif[exif.TagOffsetTimeOriginal] =
+				exif.Ascii(t.Format("-07:00"))
+		}
+	}
+	if frame.Roll.Iso > 0 && frame.Roll.Iso <= math.MaxUint16 {
+		tags.Exif[exif.TagISOSpeedRatings] =
+			exif.Short(uint16(frame.Roll.Iso))
+	}
+	if _, ok := frame.PushPull(); ok {
+		sensitivityType := frame.SensitivityType()
+		tags.Exif[exif.TagSensitivityType] =
+			exif.Short(uint16(sensitivityType))
+		if sensitivityType == e4f.SensitivityRecommendedExposureIndex {
+			tags.Exif[exif.TagRecommendedExposureIndex] =
+				exif.Long(uint32(frame.Roll.Iso))
+		}
+	}
+	if num, den, ok := frame.ExposureTime(); ok {
+		tags.Exif[exif.TagExposureTime] =
+			exif.Rational([2]uint32{uint32(num), uint32(den)})
+	}
+	if f, ok := frame.Aperture(); ok {
+		tags.Exif[exif.TagFNumber] =
+			exif.Rational(exifRational(f))
+	}
+	if exp.FocalLength > 0 {
+		tags.Exif[exif.TagFocalLength] =
+			exif.Rational(exifRational(exp.FocalLength))
+	}
+	meteringMode, lightSource := frameEnums(frame)
+	tags.Exif[exif.TagFlash] = exif.Short(uint16(frame.Flash().Value()))
+	tags.Exif[exif.TagMeteringMode] = exif.Short(uint16(meteringMode))
+	tags.Exif[exif.TagLightSource] = exif.Short(uint16(lightSource))
+	if lens := frame.Lens; lens != nil {
+		tags.Exif[exif.TagLensModel] =
+			exif.Ascii(frame.LensDescription())
+	}
+
+	if gps := frame.Gps; gps != nil {
+		tags.GPS[exif.TagGPSVersionID] = exif.Byte(e4f.GpsVersionID[:]...)
+		tags.GPS[exif.TagGPSMapDatum] = exif.Ascii(e4f.GpsMapDatum)
+		tags.GPS[exif.TagGPSLatitudeRef] =
+			exif.Ascii(string(e4f.GpsRef(gps.Lat, 'N')))
+		tags.GPS[exif.TagGPSLatitude] = gpsCoordToRationals(gps.Lat)
+		tags.GPS[exif.TagGPSLongitudeRef] =
+			exif.Ascii(string(e4f.GpsRef(gps.Long, 'E')))
+		tags.GPS[exif.TagGPSLongitude] = gpsCoordToRationals(gps.Long)
+		alt, altRef := e4f.GpsAltitude(gps.Alt)
+		tags.GPS[exif.TagGPSAltitudeRef] = exif.Byte(byte(altRef))
+		tags.GPS[exif.TagGPSAltitude] =
+			exif.Rational([2]uint32{uint32(alt), 10})
+		if t, ok := frameUTCTime(frame); ok {
+			tags.GPS[exif.TagGPSDateStamp] =
+				exif.Ascii(t.Format("2006:01:02"))
+			tags.GPS[exif.TagGPSTimeStamp] = exif.Rational(
+				[2]uint32{uint32(t.Hour()), 1},
+				[2]uint32{uint32(t.Minute()), 1},
+				[2]uint32{uint32(t.Second()), 1})
+		}
+	}
+	return tags
+}
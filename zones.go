@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitlab.com/photo/e4f-go/src/e4f"
+	"gitlab.com/photo/e4f-go/src/tzgeo"
+)
+
+// Time zone of the frames without a location.
+type zoneFallback string
+
+const (
+	// The zone of the nearest located frame of the roll.
+	zoneNearest zoneFallback = "nearest"
+	// The zone of the roll, else the -tz zone.
+	zoneDefault zoneFallback = "default"
+)
+
+func parseZoneFallback(s string) (zoneFallback, error) {
+	switch fallback := zoneFallback(s); fallback {
+	case zoneNearest, zoneDefault:
+		return fallback, nil
+	}
+	return "", fmt.Errorf("unknown time zone fallback %q", s)
+}
+
+// Parse the zones of the rolls, like "3=Europe/Paris,4=Asia/Tokyo",
+// by roll id.
+func parseRollZones(s string) (map[int]*time.Location, error) {
+	zones := make(map[int]*time.Location)
+	for _, item := range strings.Split(s, ",") {
+		id, name, found := strings.Cut(item, "=")
+		if !found {
+			return nil, fmt.Errorf("%q isn't roll=zone", item)
+		}
+		roll, err := strconv.Atoi(id)
+		if err != nil {
+			return nil, fmt.Errorf("%q: bad roll id %q", item, id)
+		}
+		if zones[roll], err = time.LoadLocation(name); err != nil {
+			return nil, err
+		}
+	}
+	return zones, nil
+}
+
+// Return the default zone of the roll id: its own if set, else def.
+func rollZone(id int, def *time.Location) *time.Location {
+	if loc, found := options.RollZones[id]; found {
+		return loc
+	}
+	return def
+}
+
+// Resolve the time zone of each exposure, by id, from its location
+// and the boundaries. Frames without a location, or outside the
+// boundaries, use the fallback. def is the default zone, for the
+// rolls without their own.
+func resolveZones(db *e4f.E4fDb, boundaries *tzgeo.Boundaries,
+	fallback zoneFallback, def *time.Location) map[int]*time.Location {
+
+	zones := make(map[int]*time.Location)
+	// Per location id.
+	cache := make(map[int]*time.Location)
+	lookup := func(gps *e4f.GpsLocation) *time.Location {
+		if loc, found := cache[gps.Id]; found {
+			return loc
+		}
+		var loc *time.Location
+		if tzid, ok := boundaries.Lookup(gps.Lat, gps.Long); ok {
+			var err error
+			loc, err = time.LoadLocation(tzid)
+			reportOnce(err)
+		}
+		cache[gps.Id] = loc
+		return loc
+	}
+
+	for _, roll := range db.ExposedRolls {
+		frames := db.FramesForRoll(roll)
+		located := make([]*time.Location, len(frames))
+		for i, frame := range frames {
+			if frame.Gps != nil {
+				located[i] = lookup(frame.Gps)
+			}
+		}
+		for i, frame := range frames {
+			loc := located[i]
+			if loc == nil && fallback == zoneNearest {
+				loc = nearestZone(located, i)
+			}
+			if loc == nil {
+				loc = rollZone(roll.Id, def)
+			}
+			zones[frame.Exposure.Id] = loc
+		}
+	}
+	return zones
+}
+
+// Return the zone of the frame nearest to index, the earliest one if
+// two are as near.
+func nearestZone(located []*time.Location, index int) *time.Location {
+	for d := 1; d < len(located); d++ {
+		if i := index - d; i >= 0 && located[i] != nil {
+			return located[i]
+		}
+		if i := index + d; i < len(located) && located[i] != nil {
+			return located[i]
+		}
+	}
+	return nil
+}
+
+// Return the time zone of the frame timestamp, and whether it is
+// known: resolved per frame, or the zone of the roll.
+func frameZone(frame *e4f.Frame) (*time.Location, bool) {
+	if loc, found := options.Zones[frame.Exposure.Id]; found {
+		return loc, true
+	}
+	if loc, found := options.RollZones[frame.Roll.Id]; found {
+		return loc, true
+	}
+	return options.TimeZone, false
+}
+
+// Return the UTC time the frame was taken.
+func frameUTCTime(frame *e4f.Frame) (time.Time, bool) {
+	loc, _ := frameZone(frame)
+	return frame.UTCTime(loc)
+}
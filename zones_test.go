@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"gitlab.com/photo/e4f-go/src/e4f"
+)
+
+func TestParseRollZones(t *testing.T) {
+	zones, err := parseRollZones("3=Europe/Paris,4=UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zones) != 2 || zones[3].String() != "Europe/Paris" ||
+		zones[4] != time.UTC {
+		t.Errorf("Zones %v", zones)
+	}
+	for _, s := range []string{"3", "x=UTC", "3=Nowhere/Nope", ""} {
+		if _, err := parseRollZones(s); err == nil {
+			t.Errorf("%q parsed", s)
+		}
+	}
+}
+
+// The zone of the frame, else of the roll, is written with the date.
+// Without either, the date has no time zone.
+func TestFrameZone(t *testing.T) {
+	db := e4f.Parse("samples/export-Roll-20130630_203650.xml")
+	roll := db.ExposedRolls[0]
+	exps := db.ExposuresForRoll(roll.Id)
+	// Frames 1 and 2 at 2013-06-30T17:51:53 and 17:54:08.
+	frame := db.Frame(roll, exps[0], 0)
+	other := db.Frame(roll, exps[1], 1)
+	defer func() {
+		options.Zones = nil
+		options.RollZones = nil
+	}()
+
+	check := func(frame *e4f.Frame, date string, offset string) {
+		t.Helper()
+		value, _ := dateTimeOriginal(frame)
+		if s := (&mappingProperty{}).format(value); s != date {
+			t.Errorf("Frame %d: date %q, expected %q", frame.Number(),
+				s, date)
+		}
+		value, ok := offsetTimeOriginal(frame)
+		if s, _ := value.(string); ok != (offset != "") || s != offset {
+			t.Errorf("Frame %d: offset %q, expected %q",
+				frame.Number(), s, offset)
+		}
+	}
+	check(frame, "2013-06-30T17:51:53", "")
+
+	options.RollZones = map[int]*time.Location{
+		roll.Id: time.FixedZone("CEST", 2*3600),
+	}
+	options.Zones = map[int]*time.Location{
+		exps[0].Id: time.FixedZone("EDT", -4*3600),
+	}
+	check(frame, "2013-06-30T17:51:53-04:00", "-04:00")
+	check(other, "2013-06-30T17:54:08+02:00", "+02:00")
+	if !isFrameDate("2013-06-30T17:54:08+02:00", other) {
+		t.Error("Date of the roll zone not the frame date")
+	}
+}